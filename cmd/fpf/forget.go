@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"fpf/internal/selections"
+)
+
+func runForget(args []string) {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "Usage: fpf forget")
+		os.Exit(2)
+	}
+
+	store, err := selections.OpenStore()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening selection history: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := store.Forget(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error clearing selection history: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(successStyle.Render("✔ Forgot learned selections"))
+	fmt.Println(mutedStyle.Render(store.Path))
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"fpf/internal/history"
+	"fpf/internal/importer"
+)
+
+func runImport(args []string) {
+	if len(args) != 2 {
+		fmt.Fprintf(os.Stderr, "Usage: fpf import <%s> <file>\n", strings.Join(importer.Kinds(), "|"))
+		os.Exit(2)
+	}
+
+	kind, path := args[0], args[1]
+
+	result, err := importer.Import(kind, path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error importing %s: %v\n", path, err)
+		os.Exit(1)
+	}
+
+	store, err := history.OpenStore()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening store: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := store.Replace(kind, result.Prompts); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving imported prompts: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(successStyle.Render(fmt.Sprintf("✔ Imported %d prompts from %d conversations", len(result.Prompts), result.Conversations)))
+	fmt.Println(mutedStyle.Render(store.Dir))
+}
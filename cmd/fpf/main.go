@@ -1,11 +1,23 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"fpf/internal/history"
+	"fpf/internal/index"
+	"fpf/internal/matcher"
+	"fpf/internal/resume"
+	"fpf/internal/selections"
 	"fpf/internal/ui"
+	"fpf/pkg/models"
 
 	"github.com/atotto/clipboard"
 	tea "github.com/charmbracelet/bubbletea"
@@ -21,7 +33,63 @@ var (
 )
 
 func main() {
-	prompts, err := history.ReadHistory()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "import":
+			runImport(os.Args[2:])
+			return
+		case "forget":
+			runForget(os.Args[2:])
+			return
+		}
+	}
+
+	rebuildCache := flag.Bool("rebuild-cache", false, "discard the history cache and re-parse every file")
+	noCache := flag.Bool("no-cache", false, "read history without using or updating the cache")
+	historyRoots := flag.String("history-roots", strings.Join(history.DefaultHistoryRoots(), string(os.PathListSeparator)), "directories to search for input history files such as .aider.input.history")
+	historyFiles := flag.String("history-files", strings.Join(history.DefaultHistoryFiles(), string(os.PathListSeparator)), "file names of plain-text input histories to read from the history roots")
+	claudeBin := flag.String("claude-bin", resume.DefaultBinaryPath(), "path to the claude binary used to resume sessions")
+	printCmd := flag.Bool("print-cmd", false, "print the resume command instead of running it")
+	sourceNames := flag.String("sources", strings.Join(history.SourceNames(), ","), "comma-separated history sources to read")
+	sortName := flag.String("sort", matcher.SortRelevance.String(), "initial sort order: relevance, recency, frequency or alphabetical")
+	matchName := flag.String("match", matcher.StrategyFuzzy.String(), "how search terms match prompts: fuzzy or bm25")
+	dedupName := flag.String("dedup", history.DedupGlobal.String(), "which copies of a prompt are merged into one row: global, project or none")
+	weightsValue := flag.String("weights", os.Getenv("FPF_WEIGHTS"), "relevance ranking weights, e.g. relevance=1,recency=0.6,frequency=0.3,half-life=168h")
+	flag.Parse()
+
+	sortMode, err := matcher.ParseSortMode(*sortName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	strategy, err := matcher.ParseStrategy(*matchName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	dedupScope, err := history.ParseDedupScope(*dedupName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	weights, err := matcher.ParseWeights(*weightsValue)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	sources, err := history.NewSources(splitList(*sourceNames), history.Options{
+		NoCache:      *noCache,
+		RebuildCache: *rebuildCache,
+		HistoryRoots: filepath.SplitList(*historyRoots),
+		HistoryFiles: filepath.SplitList(*historyFiles),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	prompts, err := history.ReadHistory(sources, dedupScope)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error reading history: %v\n", err)
 		os.Exit(1)
@@ -32,18 +100,49 @@ func main() {
 		os.Exit(1)
 	}
 
-	m := ui.NewModel(prompts)
+	grouper := history.NewGrouper(dedupScope)
+	selectionStore, picked := loadSelections()
+	searchIndex := openIndex(*noCache, *rebuildCache)
+
+	m := ui.NewModel(prompts,
+		ui.WithTranscriptLoader(history.ReadTranscript),
+		ui.WithIndex(searchIndex),
+		ui.WithGrouping(grouper.Group),
+		ui.WithMerge(grouper.Merge),
+		ui.WithRanking(sortMode, weights),
+		ui.WithStrategy(strategy),
+		ui.WithBoosts(func(query string) matcher.Boosts {
+			return selections.Boosts(picked, query, time.Now())
+		}),
+	)
 	p := tea.NewProgram(m, tea.WithAltScreen())
 
+	ctx, cancel := context.WithCancel(context.Background())
+	go history.Watch(ctx, sources, history.DefaultPollInterval, func(prompts []models.Prompt) {
+		p.Send(ui.NewPromptsMsg{Prompts: prompts})
+	})
+
 	finalModel, err := p.Run()
+	cancel()
+	if err := searchIndex.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write search index: %v\n", err)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error running program: %v\n", err)
 		os.Exit(1)
 	}
 
 	if m, ok := finalModel.(ui.Model); ok {
+		if prompt, ok := m.Resume(); ok {
+			recordSelection(selectionStore, prompt, m.Query())
+			resumeSession(prompt, *claudeBin, *printCmd)
+			return
+		}
+
 		choice := m.Choice()
 		if choice != "" {
+			recordSelection(selectionStore, models.Prompt{Display: choice}, m.Query())
+
 			if err := clipboard.WriteAll(choice); err != nil {
 				fmt.Fprintf(os.Stderr, "Error copying to clipboard: %v\n", err)
 				os.Exit(1)
@@ -54,3 +153,93 @@ func main() {
 		}
 	}
 }
+
+func loadSelections() (*selections.Store, []selections.Selection) {
+	store, err := selections.OpenStore()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to open selection history: %v\n", err)
+		return nil, nil
+	}
+
+	picked, err := store.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load selection history: %v\n", err)
+	}
+	return store, picked
+}
+
+// openIndex loads the search index kept next to the history cache. With the
+// cache disabled it is built in memory and never saved.
+func openIndex(noCache, rebuild bool) *index.Index {
+	if noCache {
+		return index.New("")
+	}
+
+	cacheDir, err := history.GetCacheDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to open search index: %v\n", err)
+		return index.New("")
+	}
+
+	path := filepath.Join(cacheDir, index.FileName)
+	if rebuild {
+		return index.New(path)
+	}
+
+	ix, err := index.Load(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load search index: %v\n", err)
+		return index.New(path)
+	}
+	return ix
+}
+
+func recordSelection(store *selections.Store, prompt models.Prompt, query string) {
+	if store == nil {
+		return
+	}
+
+	err := store.Record(selections.Selection{
+		Hash:      prompt.Hash(),
+		Timestamp: time.Now().UnixMilli(),
+		Query:     query,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record selection: %v\n", err)
+	}
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func resumeSession(prompt models.Prompt, claudeBin string, printCmd bool) {
+	if prompt.Source != history.ClaudeSourceName {
+		fmt.Fprintf(os.Stderr, "Error: resuming is only supported for %s prompts\n", history.ClaudeSourceName)
+		os.Exit(1)
+	}
+
+	runner := resume.NewRunner(claudeBin)
+	target := resume.Target{SessionID: prompt.SessionID, Dir: prompt.Project}
+
+	if printCmd {
+		fmt.Println(runner.ShellCommand(target))
+		return
+	}
+
+	fmt.Println(mutedStyle.Render(runner.ShellCommand(target)))
+	if err := runner.Run(target); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		fmt.Fprintf(os.Stderr, "Error resuming session: %v\n", err)
+		os.Exit(1)
+	}
+}
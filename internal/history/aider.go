@@ -0,0 +1,252 @@
+package history
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fpf/pkg/models"
+)
+
+const (
+	AiderSourceName        = "aider"
+	InputHistorySourceName = "input-history"
+	AiderHistoryFileName   = ".aider.input.history"
+	HistoryRootsEnvVar     = "FPF_HISTORY_ROOTS"
+	HistoryFilesEnvVar     = "FPF_HISTORY_FILES"
+	aiderTimestampPrefix   = "# "
+	aiderLinePrefix        = "+"
+)
+
+var (
+	aiderTimestampLayouts = []string{
+		"2006-01-02 15:04:05.999999",
+		"2006-01-02 15:04:05",
+	}
+	aiderCommands = map[string]bool{
+		"/add": true, "/clear": true, "/commit": true, "/diff": true,
+		"/drop": true, "/exit": true, "/git": true, "/help": true,
+		"/lint": true, "/ls": true, "/model": true, "/quit": true,
+		"/read-only": true, "/reset": true, "/run": true, "/test": true,
+		"/tokens": true, "/undo": true, "/voice": true, "/web": true,
+	}
+	inputHistorySkipDirs = map[string]bool{
+		".git":         true,
+		"node_modules": true,
+		"vendor":       true,
+		".venv":        true,
+		"Library":      true,
+	}
+)
+
+func init() {
+	RegisterSource(AiderSourceName, func(opts Options) (Source, error) {
+		return NewInputHistorySource(AiderSourceName, opts.HistoryRoots, []string{AiderHistoryFileName}, opts), nil
+	})
+	RegisterSource(InputHistorySourceName, func(opts Options) (Source, error) {
+		return NewInputHistorySource(InputHistorySourceName, opts.HistoryRoots, opts.HistoryFiles, opts), nil
+	})
+}
+
+// InputHistorySource reads flat, append-only input history files such as
+// aider's .aider.input.history. Each file belongs to the project directory
+// that contains it.
+type InputHistorySource struct {
+	SourceName string
+	Roots      []string
+	FileNames  []string
+	Options    Options
+}
+
+func NewInputHistorySource(name string, roots, fileNames []string, opts Options) *InputHistorySource {
+	return &InputHistorySource{
+		SourceName: name,
+		Roots:      roots,
+		FileNames:  fileNames,
+		Options:    opts,
+	}
+}
+
+func DefaultHistoryRoots() []string {
+	return filepath.SplitList(os.Getenv(HistoryRootsEnvVar))
+}
+
+func DefaultHistoryFiles() []string {
+	return filepath.SplitList(os.Getenv(HistoryFilesEnvVar))
+}
+
+func (s *InputHistorySource) Name() string {
+	return s.SourceName
+}
+
+func (s *InputHistorySource) Load() ([]models.Prompt, error) {
+	paths, err := s.findFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := openCache(s.Options, s.SourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	prompts, _, err := readFiles(paths, cache, s.readFile)
+	if err != nil {
+		return nil, err
+	}
+
+	saveCache(cache)
+	return prompts, nil
+}
+
+func (s *InputHistorySource) findFiles() ([]string, error) {
+	if len(s.FileNames) == 0 {
+		return nil, fmt.Errorf("%w: no %s file names configured (set %s)", ErrSourceNotFound, s.SourceName, HistoryFilesEnvVar)
+	}
+
+	names := make(map[string]bool, len(s.FileNames))
+	for _, name := range s.FileNames {
+		names[name] = true
+	}
+
+	var (
+		paths []string
+		found bool
+	)
+	for _, root := range s.Roots {
+		if _, err := os.Stat(root); err != nil {
+			continue
+		}
+		found = true
+
+		rootPaths, err := findFiles(root, inputHistorySkipDirs, func(name string) bool {
+			return names[name]
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error walking %s: %w", root, err)
+		}
+		paths = append(paths, rootPaths...)
+	}
+
+	if !found {
+		return nil, fmt.Errorf("%w: no %s history roots configured (set %s)", ErrSourceNotFound, s.SourceName, HistoryRootsEnvVar)
+	}
+
+	return paths, nil
+}
+
+func (s *InputHistorySource) readFile(path string, start filePosition) ([]models.Prompt, filePosition, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, filePosition{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, filePosition{}, err
+	}
+
+	if start.Offset > 0 {
+		if _, err := file.Seek(start.Offset, io.SeekStart); err != nil {
+			return nil, filePosition{}, err
+		}
+	}
+
+	project := filepath.Dir(path)
+	fallback := info.ModTime().UnixMilli()
+	reader := bufio.NewReader(file)
+
+	var (
+		prompts   []models.Prompt
+		entry     []string
+		entryLine int
+		timestamp int64
+		end       = start
+	)
+
+	flush := func() {
+		message := strings.Join(entry, "\n")
+		entry = entry[:0]
+		if shouldSkipInputHistoryMessage(message) {
+			return
+		}
+
+		ts := timestamp
+		if ts == 0 {
+			ts = fallback
+		}
+		prompts = append(prompts, models.Prompt{
+			Display:   message,
+			Timestamp: ts,
+			Project:   project,
+			Source:    s.SourceName,
+			File:      path,
+			Line:      entryLine,
+		})
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, filePosition{}, err
+		}
+		if !strings.HasSuffix(line, "\n") {
+			break
+		}
+		end.Offset += int64(len(line))
+		end.Line++
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case strings.HasPrefix(line, aiderTimestampPrefix):
+			if len(entry) > 0 {
+				flush()
+			}
+			timestamp = parseAiderTimestamp(strings.TrimPrefix(line, aiderTimestampPrefix))
+		case strings.HasPrefix(line, aiderLinePrefix):
+			if len(entry) == 0 {
+				entryLine = end.Line
+			}
+			entry = append(entry, strings.TrimPrefix(line, aiderLinePrefix))
+		case strings.TrimSpace(line) != "":
+			if len(entry) > 0 {
+				flush()
+			}
+			timestamp = 0
+			entryLine = end.Line
+			entry = append(entry, line)
+			flush()
+		}
+	}
+
+	if len(entry) > 0 {
+		flush()
+	}
+
+	return prompts, end, nil
+}
+
+func parseAiderTimestamp(value string) int64 {
+	value = strings.TrimSpace(value)
+	for _, layout := range aiderTimestampLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t.UnixMilli()
+		}
+	}
+	return 0
+}
+
+func shouldSkipInputHistoryMessage(message string) bool {
+	if shouldSkipMessage(message) {
+		return true
+	}
+
+	command, _, _ := strings.Cut(strings.TrimSpace(message), " ")
+	return aiderCommands[command]
+}
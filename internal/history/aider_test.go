@@ -0,0 +1,87 @@
+package history
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"fpf/pkg/models"
+)
+
+func TestInputHistorySourceLoad(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "code", "api")
+	if err := os.MkdirAll(filepath.Join(project, "node_modules", "dep"), 0o755); err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+
+	history := "\n# 2024-05-20 14:31:05.123456\n+refactor the session store\n+so it uses redis\n" +
+		"\n# 2024-05-20 14:35:00.000000\n+/add internal/session.go\n" +
+		"\n# 2024-05-20 14:40:00\n+/ask why is the cache cold?\n"
+	historyPath := filepath.Join(project, AiderHistoryFileName)
+	if err := os.WriteFile(historyPath, []byte(history), 0o644); err != nil {
+		t.Fatalf("Failed to write history: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(project, "node_modules", "dep", AiderHistoryFileName), []byte("+ignored\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write history: %v", err)
+	}
+
+	source := NewInputHistorySource(AiderSourceName, []string{root}, []string{AiderHistoryFileName}, Options{NoCache: true})
+	got, err := source.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := []models.Prompt{
+		{
+			Display:   "refactor the session store\nso it uses redis",
+			Timestamp: time.Date(2024, 5, 20, 14, 31, 5, 123456000, time.Local).UnixMilli(),
+			Project:   project,
+			Source:    AiderSourceName,
+			File:      historyPath,
+			Line:      3,
+		},
+		{
+			Display:   "/ask why is the cache cold?",
+			Timestamp: time.Date(2024, 5, 20, 14, 40, 0, 0, time.Local).UnixMilli(),
+			Project:   project,
+			Source:    AiderSourceName,
+			File:      historyPath,
+			Line:      10,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load() = %v, want %v", got, want)
+	}
+}
+
+func TestInputHistorySourcePlainText(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, ".repl_history")
+	if err := os.WriteFile(path, []byte("first command\n\nsecond command\npartial"), 0o644); err != nil {
+		t.Fatalf("Failed to write history: %v", err)
+	}
+
+	source := NewInputHistorySource(InputHistorySourceName, []string{root}, []string{".repl_history"}, Options{NoCache: true})
+	got, end, err := source.readFile(path, filePosition{})
+	if err != nil {
+		t.Fatalf("readFile() error = %v", err)
+	}
+
+	if len(got) != 2 || got[0].Display != "first command" || got[1].Display != "second command" {
+		t.Errorf("readFile() = %v, want [first command second command]", got)
+	}
+	if want := int64(len("first command\n\nsecond command\n")); end.Offset != want || end.Line != 3 {
+		t.Errorf("readFile() end = %+v, want offset %d at line 3", end, want)
+	}
+}
+
+func TestInputHistorySourceUnconfigured(t *testing.T) {
+	source := NewInputHistorySource(InputHistorySourceName, nil, []string{".repl_history"}, Options{NoCache: true})
+	if _, err := source.Load(); !errors.Is(err, ErrSourceNotFound) {
+		t.Errorf("Load() error = %v, want ErrSourceNotFound", err)
+	}
+}
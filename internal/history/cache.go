@@ -0,0 +1,120 @@
+package history
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"fpf/pkg/models"
+)
+
+const cacheVersion = 5
+
+type CachedFile struct {
+	Size    int64
+	ModTime int64
+	Offset  int64
+	Line    int
+	Prompts []models.Prompt
+}
+
+type Cache struct {
+	Version int
+	Files   map[string]CachedFile
+
+	path string
+}
+
+func GetCacheDir() (string, error) {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "fpf"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".cache", "fpf"), nil
+}
+
+func NewCache(path string) *Cache {
+	return &Cache{
+		Version: cacheVersion,
+		Files:   make(map[string]CachedFile),
+		path:    path,
+	}
+}
+
+func LoadCache(path string) (*Cache, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return NewCache(path), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var cache Cache
+	if err := gob.NewDecoder(file).Decode(&cache); err != nil || cache.Version != cacheVersion {
+		return NewCache(path), nil
+	}
+
+	if cache.Files == nil {
+		cache.Files = make(map[string]CachedFile)
+	}
+	cache.path = path
+	return &cache, nil
+}
+
+func (c *Cache) Save() error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(c); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), c.path)
+}
+
+func (c *Cache) lookup(path string, info os.FileInfo) (CachedFile, bool) {
+	if c == nil {
+		return CachedFile{}, false
+	}
+
+	entry, ok := c.Files[path]
+	if !ok || info.Size() < entry.Size {
+		return CachedFile{}, false
+	}
+	if info.Size() == entry.Size && info.ModTime().UnixNano() != entry.ModTime {
+		return CachedFile{}, false
+	}
+
+	return entry, true
+}
+
+func (c *Cache) replace(paths []string, entries []CachedFile) {
+	if c == nil {
+		return
+	}
+
+	files := make(map[string]CachedFile, len(paths))
+	for i, path := range paths {
+		files[path] = entries[i]
+	}
+	c.Files = files
+}
@@ -0,0 +1,174 @@
+package history
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func appendJSONL(t *testing.T, path string, raw string) {
+	t.Helper()
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(raw); err != nil {
+		t.Fatalf("Failed to append to %s: %v", path, err)
+	}
+}
+
+func marshalLine(t *testing.T, v any) string {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Failed to marshal line: %v", err)
+	}
+	return string(data)
+}
+
+func displays(entry CachedFile) []string {
+	result := make([]string, len(entry.Prompts))
+	for i, p := range entry.Prompts {
+		result[i] = p.Display
+	}
+	return result
+}
+
+func TestReadCachedJSONLFile(t *testing.T) {
+	ts := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	writeJSONL(t, path, userEntry("first", "/p", ts), userEntry("second", "/p", ts))
+
+	cache := NewCache(filepath.Join(t.TempDir(), "history.gob"))
+	entry, err := readCachedFile(path, cache, readJSONLFile)
+	if err != nil {
+		t.Fatalf("readCachedFile() error = %v", err)
+	}
+	if got := displays(entry); len(got) != 2 {
+		t.Fatalf("initial read returned %v, want 2 prompts", got)
+	}
+	if entry.Line != 1 || entry.Offset >= entry.Size {
+		t.Errorf("initial read stopped at %d (line %d), want before the last prompt on line 2", entry.Offset, entry.Line)
+	}
+	cache.Files[path] = entry
+
+	t.Run("unchanged file is served from cache", func(t *testing.T) {
+		stale := entry
+		stale.Prompts = append(stale.Prompts[:0:0], stale.Prompts[0])
+		cache.Files[path] = stale
+		defer func() { cache.Files[path] = entry }()
+
+		got, err := readCachedFile(path, cache, readJSONLFile)
+		if err != nil {
+			t.Fatalf("readCachedFile() error = %v", err)
+		}
+		if len(got.Prompts) != 1 {
+			t.Errorf("unchanged read returned %d prompts, want cached 1", len(got.Prompts))
+		}
+	})
+
+	t.Run("appended lines are parsed from the stored offset", func(t *testing.T) {
+		appendJSONL(t, path, marshalLine(t, userEntry("third", "/p", ts))+"\n")
+
+		got, err := readCachedFile(path, cache, readJSONLFile)
+		if err != nil {
+			t.Fatalf("readCachedFile() error = %v", err)
+		}
+		want := []string{"first", "second", "third"}
+		if d := displays(got); len(d) != len(want) || d[2] != "third" {
+			t.Errorf("appended read returned %v, want %v", d, want)
+		}
+		cache.Files[path] = got
+		entry = got
+	})
+
+	t.Run("partial trailing line is re-read once complete", func(t *testing.T) {
+		line := marshalLine(t, userEntry("fourth", "/p", ts))
+		appendJSONL(t, path, line[:len(line)/2])
+
+		partial, err := readCachedFile(path, cache, readJSONLFile)
+		if err != nil {
+			t.Fatalf("readCachedFile() error = %v", err)
+		}
+		if len(partial.Prompts) != 3 {
+			t.Errorf("partial read returned %d prompts, want 3", len(partial.Prompts))
+		}
+		if partial.Offset != entry.Offset {
+			t.Errorf("partial read offset = %d, want %d", partial.Offset, entry.Offset)
+		}
+		cache.Files[path] = partial
+
+		appendJSONL(t, path, line[len(line)/2:]+"\n")
+		complete, err := readCachedFile(path, cache, readJSONLFile)
+		if err != nil {
+			t.Fatalf("readCachedFile() error = %v", err)
+		}
+		if d := displays(complete); len(d) != 4 || d[3] != "fourth" {
+			t.Errorf("completed read returned %v, want fourth prompt appended", d)
+		}
+		cache.Files[path] = complete
+	})
+
+	t.Run("rewritten file is parsed from scratch", func(t *testing.T) {
+		writeJSONL(t, path, userEntry("rewritten", "/p", ts))
+
+		got, err := readCachedFile(path, cache, readJSONLFile)
+		if err != nil {
+			t.Fatalf("readCachedFile() error = %v", err)
+		}
+		if d := displays(got); len(d) != 1 || d[0] != "rewritten" {
+			t.Errorf("rewritten read returned %v, want [rewritten]", d)
+		}
+	})
+}
+
+func TestCacheSaveAndLoad(t *testing.T) {
+	root := buildFixtureTree(t, 2, 3, 4)
+	cachePath := filepath.Join(t.TempDir(), "fpf", "history.gob")
+
+	cache := NewCache(cachePath)
+	want, _, err := readProjects(root, cache)
+	if err != nil {
+		t.Fatalf("readProjects() error = %v", err)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := LoadCache(cachePath)
+	if err != nil {
+		t.Fatalf("LoadCache() error = %v", err)
+	}
+	if len(loaded.Files) != 6 {
+		t.Fatalf("LoadCache() has %d files, want 6", len(loaded.Files))
+	}
+
+	got, _, err := readProjects(root, loaded)
+	if err != nil {
+		t.Fatalf("readProjects() error = %v", err)
+	}
+	if len(got) != len(want) {
+		t.Errorf("cached readProjects() returned %d prompts, want %d", len(got), len(want))
+	}
+}
+
+func TestLoadCacheCorrupt(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), "history.gob")
+	if err := os.WriteFile(cachePath, []byte("not a cache"), 0o644); err != nil {
+		t.Fatalf("Failed to write cache: %v", err)
+	}
+
+	cache, err := LoadCache(cachePath)
+	if err != nil {
+		t.Fatalf("LoadCache() error = %v", err)
+	}
+	if len(cache.Files) != 0 {
+		t.Errorf("LoadCache() of corrupt file has %d files, want 0", len(cache.Files))
+	}
+}
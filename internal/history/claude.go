@@ -0,0 +1,220 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fpf/pkg/models"
+)
+
+const ClaudeSourceName = "claude"
+
+func init() {
+	RegisterSource(ClaudeSourceName, func(opts Options) (Source, error) {
+		projectsPath, err := GetProjectsPath()
+		if err != nil {
+			return nil, err
+		}
+		return NewClaudeSource(projectsPath, opts), nil
+	})
+}
+
+type JSONLEntry struct {
+	Type        string  `json:"type"`
+	IsMeta      *bool   `json:"isMeta"`
+	IsSidechain bool    `json:"isSidechain"`
+	Cwd         string  `json:"cwd"`
+	SessionID   string  `json:"sessionId"`
+	GitBranch   string  `json:"gitBranch"`
+	Version     string  `json:"version"`
+	Message     Message `json:"message"`
+	UUID        string  `json:"uuid"`
+	ParentUUID  string  `json:"parentUuid"`
+	Timestamp   string  `json:"timestamp"`
+}
+
+type Message struct {
+	Role    string      `json:"role"`
+	Model   string      `json:"model"`
+	Content interface{} `json:"content"`
+}
+
+const syntheticModel = "<synthetic>"
+
+func GetProjectsPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".claude", "projects"), nil
+}
+
+type ClaudeSource struct {
+	ProjectsPath string
+	Options      Options
+
+	loaded map[string]watchedFile
+}
+
+func NewClaudeSource(projectsPath string, opts Options) *ClaudeSource {
+	return &ClaudeSource{
+		ProjectsPath: projectsPath,
+		Options:      opts,
+	}
+}
+
+func (s *ClaudeSource) Name() string {
+	return ClaudeSourceName
+}
+
+func (s *ClaudeSource) Load() ([]models.Prompt, error) {
+	if _, err := os.Stat(s.ProjectsPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: projects directory does not exist: %s", ErrSourceNotFound, s.ProjectsPath)
+	}
+
+	cache, err := openCache(s.Options, ClaudeSourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	prompts, loaded, err := readProjects(s.ProjectsPath, cache)
+	if err != nil {
+		return nil, err
+	}
+	s.loaded = loaded
+
+	saveCache(cache)
+	return prompts, nil
+}
+
+func (s *ClaudeSource) NewWatcher() (*Watcher, error) {
+	return newWatcher(func() ([]string, error) {
+		return findJSONLFiles(s.ProjectsPath)
+	}, readJSONLFile, s.loaded)
+}
+
+func readProjects(projectsPath string, cache *Cache) ([]models.Prompt, map[string]watchedFile, error) {
+	paths, err := findJSONLFiles(projectsPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error walking projects directory: %w", err)
+	}
+
+	return readFiles(paths, cache, readJSONLFile)
+}
+
+// readJSONLFile reads the prompts in a Claude Code session file and links
+// each one to the assistant messages that answer it by following the
+// parentUuid chain. The returned position stops before the last prompt, so
+// its reply is picked up once more of it has been written.
+func readJSONLFile(path string, start filePosition) ([]models.Prompt, filePosition, error) {
+	prompts := make([]models.Prompt, 0, 64)
+	owners := make(map[string]int)
+	var lastPrompt *filePosition
+
+	end, err := scanJSONL(path, start, func(line []byte, before filePosition) bool {
+		var entry JSONLEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return false
+		}
+
+		if prompt, ok := entry.prompt(); ok {
+			prompt.File, prompt.Line = path, before.Line+1
+			prompts = append(prompts, prompt)
+			if entry.UUID != "" {
+				owners[entry.UUID] = len(prompts) - 1
+			}
+			lastPrompt = &before
+			return true
+		}
+
+		owner, ok := owners[entry.ParentUUID]
+		if !ok || entry.UUID == "" {
+			return true
+		}
+		if entry.Type == "user" && extractMessageContent(entry.Message.Content) != "" {
+			return true
+		}
+
+		owners[entry.UUID] = owner
+		if entry.Type == "assistant" {
+			linkReply(&prompts[owner], entry)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, filePosition{}, err
+	}
+
+	if lastPrompt != nil && lastPrompt.Offset < end.Offset {
+		end = *lastPrompt
+	}
+
+	return prompts, end, nil
+}
+
+func linkReply(prompt *models.Prompt, entry JSONLEntry) {
+	if model := entry.Message.Model; prompt.Model == "" && model != "" && model != syntheticModel {
+		prompt.Model = model
+	}
+
+	text, tools := extractAssistantContent(entry.Message.Content)
+	if text != "" {
+		prompt.Replies = append(prompt.Replies, text)
+	}
+	prompt.Tools = append(prompt.Tools, tools...)
+}
+
+func (entry JSONLEntry) prompt() (models.Prompt, bool) {
+	if entry.Type != "user" || entry.Message.Role != "user" {
+		return models.Prompt{}, false
+	}
+	if entry.IsMeta != nil && *entry.IsMeta {
+		return models.Prompt{}, false
+	}
+
+	message := extractMessageContent(entry.Message.Content)
+	if message == "" {
+		return models.Prompt{}, false
+	}
+
+	if shouldSkipMessage(message) {
+		return models.Prompt{}, false
+	}
+
+	return models.Prompt{
+		Display:     message,
+		Timestamp:   parseTimestamp(entry.Timestamp),
+		Project:     entry.Cwd,
+		Source:      ClaudeSourceName,
+		SessionID:   entry.SessionID,
+		UUID:        entry.UUID,
+		ParentUUID:  entry.ParentUUID,
+		GitBranch:   entry.GitBranch,
+		Version:     entry.Version,
+		IsSidechain: entry.IsSidechain,
+	}, true
+}
+
+func extractMessageContent(content interface{}) string {
+	switch v := content.(type) {
+	case string:
+		return v
+	case []interface{}:
+		var textParts []string
+		for _, item := range v {
+			if obj, ok := item.(map[string]interface{}); ok {
+				if typ, ok := obj["type"].(string); ok && typ == "text" {
+					if text, ok := obj["text"].(string); ok {
+						textParts = append(textParts, text)
+					}
+				}
+			}
+		}
+		return strings.Join(textParts, " ")
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,330 @@
+package history
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+
+	"fpf/pkg/models"
+)
+
+const (
+	// NearDuplicateThreshold is how much of two prompts' text, measured as the
+	// Jaccard similarity of their character trigrams, has to be shared for
+	// them to be grouped as variants of each other.
+	NearDuplicateThreshold = 0.8
+
+	shingleSize = 3
+	// Signatures of signatureSize MinHashes are split into bands of
+	// bandSize. Prompts sharing a band are compared, which finds nearly every
+	// pair above the threshold while comparing few pairs below it.
+	signatureSize = 32
+	bandSize      = 4
+	// minAgreement is how many MinHashes two signatures have to share before
+	// their texts are compared. Texts at the threshold share about 26 of 32,
+	// so this skips few pairs above it.
+	minAgreement = 20
+	// maxBucketSize caps how many prompts a band's bucket holds, so texts that
+	// often collide, such as short common prompts, don't make each new prompt
+	// compare against all of them. Prompts left out of a full bucket are still
+	// compared against it, and are found through their other bands.
+	maxBucketSize = 64
+)
+
+type (
+	signature [signatureSize]uint32
+	bandKey   [bandSize + 1]uint32
+)
+
+// Grouper collapses near-duplicate prompts, ones that only differ by case,
+// whitespace, a path or a typo, into a single prompt listing the others as
+// its Variants. Prompts are only grouped with ones they would be
+// deduplicated with in its scope.
+//
+// It keeps what it has grouped, so merging new prompts only compares them
+// against the prompts they share a band with.
+type Grouper struct {
+	scope DedupScope
+
+	mu sync.Mutex
+	// entries holds one prompt per dedup key, in the order they were first
+	// seen, with the normalized text, signature, number of shingles and, once
+	// compared, the shingles of each.
+	entries    []models.Prompt
+	texts      []string
+	signatures []signature
+	sizes      []int
+	shingles   [][]uint64
+	keys       map[string]int
+	normalized map[string]int
+	buckets    map[bandKey][]int
+	groups     unionFind
+	// last is what the previous Group or Merge returned.
+	last []models.Prompt
+}
+
+func NewGrouper(scope DedupScope) *Grouper {
+	g := &Grouper{scope: scope}
+	g.reset()
+	return g
+}
+
+// Group deduplicates prompts and then groups near-duplicates, keeping the
+// most recent prompt of each group with the rest as its Variants, newest
+// first. Prompts that are already grouped are regrouped from scratch.
+func (g *Grouper) Group(prompts []models.Prompt) []models.Prompt {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.reset()
+	g.addAll(prompts)
+	return g.result()
+}
+
+// Merge groups incoming prompts into existing ones. When existing is what the
+// previous Group or Merge returned, only the incoming prompts are compared;
+// otherwise everything is regrouped.
+func (g *Grouper) Merge(existing, incoming []models.Prompt) []models.Prompt {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(existing) != len(g.last) || len(existing) > 0 && &existing[0] != &g.last[0] {
+		g.reset()
+		g.addAll(existing)
+	}
+	g.addAll(incoming)
+	return g.result()
+}
+
+func (g *Grouper) reset() {
+	g.entries, g.texts, g.signatures, g.sizes, g.shingles = nil, nil, nil, nil, nil
+	g.keys = make(map[string]int)
+	g.normalized = make(map[string]int)
+	g.buckets = make(map[bandKey][]int)
+	g.groups = nil
+	g.last = nil
+}
+
+func (g *Grouper) addAll(prompts []models.Prompt) {
+	for _, p := range prompts {
+		for _, version := range p.Versions() {
+			g.add(version)
+		}
+	}
+}
+
+// add merges p into the entry with the same dedup key, or adds it as a new
+// entry joined to the entries it is a near-duplicate of.
+func (g *Grouper) add(p models.Prompt) {
+	key, ok := g.scope.key(p, p.Display)
+	if i, seen := g.keys[key]; ok && seen {
+		g.entries[i].Absorb(p)
+		return
+	}
+
+	i := len(g.entries)
+	p = p.Hashed()
+	p.Uses = p.UseCount()
+	p.Occurrences = slices.Clip(p.Occurrences)
+	g.entries = append(g.entries, p)
+	g.groups = append(g.groups, i)
+	if ok {
+		g.keys[key] = i
+	}
+	if g.scope == DedupNone {
+		return
+	}
+
+	text := normalizeText(p.Display)
+	g.texts = append(g.texts, text)
+	g.shingles = append(g.shingles, nil)
+	normalizedKey, _ := g.scope.key(p, text)
+	if j, ok := g.normalized[normalizedKey]; ok {
+		g.signatures = append(g.signatures, signature{})
+		g.sizes = append(g.sizes, 0)
+		g.groups.union(j, i)
+		return
+	}
+	g.normalized[normalizedKey] = i
+
+	sig, size := minHash(text)
+	g.signatures = append(g.signatures, sig)
+	g.sizes = append(g.sizes, size)
+	for band := 0; band < signatureSize/bandSize; band++ {
+		key := bandKey{uint32(band)}
+		copy(key[1:], sig[band*bandSize:(band+1)*bandSize])
+
+		bucket := g.buckets[key]
+		for _, j := range bucket {
+			if g.similar(i, j) {
+				g.groups.union(i, j)
+			}
+		}
+		if len(bucket) < maxBucketSize {
+			g.buckets[key] = append(bucket, i)
+		}
+	}
+}
+
+// similar reports whether entries i and j, not yet in the same group, are
+// near-duplicates. The cheap checks run first, as most pairs sharing a band
+// are not.
+func (g *Grouper) similar(i, j int) bool {
+	if g.scope == DedupProject && g.entries[i].Project != g.entries[j].Project {
+		return false
+	}
+	// Sets that differ this much in size can't overlap enough.
+	if smaller, larger := min(g.sizes[i], g.sizes[j]), max(g.sizes[i], g.sizes[j]); float64(smaller) < NearDuplicateThreshold*float64(larger) {
+		return false
+	}
+
+	agreement := 0
+	for n := range g.signatures[i] {
+		if g.signatures[i][n] == g.signatures[j][n] {
+			agreement++
+		}
+	}
+	if agreement < minAgreement || g.groups.find(i) == g.groups.find(j) {
+		return false
+	}
+
+	return overlap(g.shinglesOf(i), g.shinglesOf(j)) >= NearDuplicateThreshold
+}
+
+func (g *Grouper) shinglesOf(i int) []uint64 {
+	if g.shingles[i] == nil {
+		g.shingles[i] = shingles(g.texts[i])
+	}
+	return g.shingles[i]
+}
+
+// result returns a prompt per group, most recent first.
+func (g *Grouper) result() []models.Prompt {
+	newer := func(a, b int) int {
+		return cmp.Or(cmp.Compare(g.entries[b].Timestamp, g.entries[a].Timestamp), cmp.Compare(a, b))
+	}
+
+	members := make([][]int, len(g.entries))
+	var roots []int
+	for i := range g.entries {
+		root := g.groups.find(i)
+		if members[root] == nil {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], i)
+	}
+	for _, root := range roots {
+		slices.SortFunc(members[root], newer)
+	}
+	slices.SortFunc(roots, func(a, b int) int {
+		return newer(members[a][0], members[b][0])
+	})
+
+	result := make([]models.Prompt, len(roots))
+	for n, root := range roots {
+		group := members[root]
+		result[n] = g.entries[group[0]]
+		for _, i := range group[1:] {
+			result[n].Variants = append(result[n].Variants, g.entries[i])
+		}
+	}
+	g.last = result
+	return result
+}
+
+// minHash returns the MinHash signature of text's trigrams and how many
+// distinct trigrams it has.
+func minHash(text string) (signature, int) {
+	var sig signature
+	for i := range sig {
+		sig[i] = ^uint32(0)
+	}
+	set := shingles(text)
+	for _, shingle := range set {
+		for i := range sig {
+			sig[i] = min(sig[i], uint32(mix(shingle^minHashSeeds[i])))
+		}
+	}
+	return sig, len(set)
+}
+
+var minHashSeeds = func() (seeds [signatureSize]uint64) {
+	for i := range seeds {
+		seeds[i] = mix(uint64(i + 1))
+	}
+	return seeds
+}()
+
+// mix is the splitmix64 finaliser, used as a family of hash functions by
+// seeding its input.
+func mix(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// normalizeText lower-cases text and collapses its whitespace, so prompts that
+// only differ in those are always grouped.
+func normalizeText(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+// shingles returns the distinct trigrams of text, packed into integers. Text
+// shorter than a trigram is a single shingle.
+func shingles(text string) []uint64 {
+	runes := []rune(text)
+	if len(runes) < shingleSize {
+		var packed uint64
+		for _, r := range runes {
+			packed = packed<<21 | uint64(r)
+		}
+		return []uint64{packed}
+	}
+
+	result := make([]uint64, 0, len(runes)-shingleSize+1)
+	for i := 0; i+shingleSize <= len(runes); i++ {
+		result = append(result, uint64(runes[i])<<42|uint64(runes[i+1])<<21|uint64(runes[i+2]))
+	}
+	slices.Sort(result)
+	return slices.Compact(result)
+}
+
+// overlap returns the Jaccard similarity of two sorted sets of shingles.
+func overlap(x, y []uint64) float64 {
+	shared := 0
+	for i, j := 0, 0; i < len(x) && j < len(y); {
+		switch {
+		case x[i] < y[j]:
+			i++
+		case x[i] > y[j]:
+			j++
+		default:
+			shared++
+			i++
+			j++
+		}
+	}
+	return float64(shared) / float64(len(x)+len(y)-shared)
+}
+
+type unionFind []int
+
+func (u unionFind) find(i int) int {
+	for u[i] != i {
+		u[i] = u[u[i]]
+		i = u[i]
+	}
+	return i
+}
+
+// union keeps the smaller index as the root, so each group's root is its
+// earliest member.
+func (u unionFind) union(i, j int) {
+	i, j = u.find(i), u.find(j)
+	if i > j {
+		i, j = j, i
+	}
+	u[j] = i
+}
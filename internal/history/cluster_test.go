@@ -0,0 +1,183 @@
+package history
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+
+	"fpf/pkg/models"
+)
+
+func texts(prompts []models.Prompt) []string {
+	result := make([]string, len(prompts))
+	for i, p := range prompts {
+		result[i] = p.Display
+	}
+	return result
+}
+
+func TestGrouperGroup(t *testing.T) {
+	prompts := []models.Prompt{
+		{Display: "run the tests", Timestamp: 1, Project: "/one"},
+		{Display: "Run the tests ", Timestamp: 4, Project: "/two"},
+		{Display: "explain what /home/me/api/internal/auth/session.go does", Timestamp: 2, Project: "/api"},
+		{Display: "explain what /home/me/web/internal/auth/session.go does", Timestamp: 5, Project: "/web"},
+		{Display: "refactor the database layer to use connection pooling", Timestamp: 3},
+		{Display: "refactor the databse layer to use connection pooling", Timestamp: 6},
+		{Display: "run the linter", Timestamp: 7},
+		{Display: "run the tests", Timestamp: 0, Project: "/three"},
+	}
+
+	got := NewGrouper(DedupGlobal).Group(prompts)
+
+	want := [][]string{
+		{"run the linter"},
+		{"refactor the databse layer to use connection pooling", "refactor the database layer to use connection pooling"},
+		{"explain what /home/me/web/internal/auth/session.go does", "explain what /home/me/api/internal/auth/session.go does"},
+		{"Run the tests ", "run the tests"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Group() returned %d prompts, want %d: %v", len(got), len(want), texts(got))
+	}
+	for i, p := range got {
+		if versions := texts(p.Versions()); !reflect.DeepEqual(versions, want[i]) {
+			t.Errorf("group %d = %q, want %q", i, versions, want[i])
+		}
+	}
+
+	tests := got[3]
+	if tests.Timestamp != 4 || tests.Project != "/two" {
+		t.Errorf("group kept %v from %s, want the newest variant", tests.Timestamp, tests.Project)
+	}
+	if tests.UseCount() != 3 || tests.Variants[0].Uses != 2 {
+		t.Errorf("group UseCount() = %d with variant uses %d, want 3 and 2", tests.UseCount(), tests.Variants[0].Uses)
+	}
+}
+
+func TestGrouperMerge(t *testing.T) {
+	prompts := []models.Prompt{
+		{Display: "deploy the api to staging", Timestamp: 1},
+		{Display: "update the changelog", Timestamp: 2},
+		{Display: "deploy the api to staging!", Timestamp: 3},
+		{Display: "deploy the web app to staging", Timestamp: 4},
+		{Display: "update the changelog", Timestamp: 5},
+	}
+
+	g := NewGrouper(DedupGlobal)
+	merged := g.Group(prompts[:2])
+	merged = g.Merge(merged, prompts[2:4])
+	merged = g.Merge(merged, prompts[4:])
+
+	want := NewGrouper(DedupGlobal).Group(prompts)
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("Merge() = %v, want %v", merged, want)
+	}
+
+	// Prompts the Grouper didn't return are regrouped from scratch.
+	if got := g.Merge(slices.Clone(prompts[:1]), prompts[2:3]); len(got) != 1 || len(got[0].Variants) != 1 {
+		t.Errorf("Merge() into other prompts = %v, want just the api deploys, grouped", got)
+	}
+	if len(want) != 3 || len(want[2].Variants) != 1 {
+		t.Errorf("Group() = %v, want the api deploys grouped", want)
+	}
+}
+
+func TestOverlap(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected float64
+	}{
+		{"abc", "abc", 1},
+		{"abcd", "abce", 1.0 / 3},
+		{"ab", "ab", 1},
+		{"ab", "ac", 0},
+	}
+
+	for _, tt := range tests {
+		if got := overlap(shingles(tt.a), shingles(tt.b)); got != tt.expected {
+			t.Errorf("overlap(shingles(%q), shingles(%q)) = %v, want %v", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
+
+func TestGrouperScope(t *testing.T) {
+	prompts := []models.Prompt{
+		{Display: "run the tests", Timestamp: 1, Project: "/one"},
+		{Display: "Run the tests", Timestamp: 2, Project: "/two"},
+		{Display: "run the tests", Timestamp: 3, Project: "/two"},
+	}
+
+	tests := []struct {
+		scope DedupScope
+		want  []int
+	}{
+		{DedupGlobal, []int{3}},
+		{DedupProject, []int{2, 1}},
+		{DedupNone, []int{1, 1, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scope.String(), func(t *testing.T) {
+			var got []int
+			for _, p := range NewGrouper(tt.scope).Group(prompts) {
+				got = append(got, p.UseCount())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Group() use counts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGrouperMergeReread(t *testing.T) {
+	g := NewGrouper(DedupGlobal)
+	grouped := g.Group([]models.Prompt{
+		{Display: "run the tests", Timestamp: 9, File: "b.jsonl", Line: 1},
+		{Display: "Run the tests ", Timestamp: 5, File: "a.jsonl", Line: 4},
+	})
+
+	tests := []struct {
+		name   string
+		reread models.Prompt
+		reply  func(models.Prompt) string
+	}{
+		{
+			"group",
+			models.Prompt{Display: "run the tests", Timestamp: 9, File: "b.jsonl", Line: 1, Replies: []string{"All pass."}},
+			func(p models.Prompt) string { return p.FirstReply() },
+		},
+		{
+			"variant",
+			models.Prompt{Display: "Run the tests ", Timestamp: 5, File: "a.jsonl", Line: 4, Replies: []string{"One failure."}},
+			func(p models.Prompt) string { return p.Variants[0].FirstReply() },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := g.Merge(grouped, []models.Prompt{tt.reread})
+			if len(merged) != 1 || merged[0].UseCount() != 2 {
+				t.Fatalf("Merge() = %v, want one group used twice", merged)
+			}
+			if got := tt.reply(merged[0]); got != tt.reread.FirstReply() {
+				t.Errorf("Merge() kept reply %q, want the re-read copy's %q", got, tt.reread.FirstReply())
+			}
+		})
+	}
+}
+
+func TestGrouperBucketSize(t *testing.T) {
+	var prompts []models.Prompt
+	for i := range 1000 {
+		prompts = append(prompts, models.Prompt{Display: fmt.Sprintf("continue %d", i), Timestamp: int64(i)})
+	}
+
+	g := NewGrouper(DedupGlobal)
+	g.Group(prompts)
+	for key, bucket := range g.buckets {
+		if len(bucket) > maxBucketSize {
+			t.Fatalf("bucket %v holds %d prompts, want at most %d", key, len(bucket), maxBucketSize)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"fpf/pkg/models"
+)
+
+const CodexSourceName = "codex"
+
+func init() {
+	RegisterSource(CodexSourceName, func(opts Options) (Source, error) {
+		sessionsPath, err := GetCodexSessionsPath()
+		if err != nil {
+			return nil, err
+		}
+		return NewCodexSource(sessionsPath, opts), nil
+	})
+}
+
+type codexLine struct {
+	Timestamp string          `json:"timestamp"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	ID        string          `json:"id"`
+	codexItem
+}
+
+type codexItem struct {
+	Type       string         `json:"type"`
+	Role       string         `json:"role"`
+	Content    []codexContent `json:"content"`
+	Cwd        string         `json:"cwd"`
+	Timestamp  string         `json:"timestamp"`
+	ID         string         `json:"id"`
+	CLIVersion string         `json:"cli_version"`
+	Model      string         `json:"model"`
+	Git        struct {
+		Branch string `json:"branch"`
+	} `json:"git"`
+}
+
+type codexContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type codexSession struct {
+	id        string
+	cwd       string
+	timestamp string
+	version   string
+	branch    string
+	model     string
+}
+
+var (
+	codexSkipPrefixes = []string{
+		"<environment_context>",
+		"<user_instructions>",
+		"# AGENTS.md instructions",
+	}
+	codexCwdPattern = regexp.MustCompile(`<cwd>([^<]+)</cwd>`)
+)
+
+func GetCodexSessionsPath() (string, error) {
+	if dir := os.Getenv("CODEX_HOME"); dir != "" {
+		return filepath.Join(dir, "sessions"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".codex", "sessions"), nil
+}
+
+type CodexSource struct {
+	SessionsPath string
+	Options      Options
+
+	loaded map[string]watchedFile
+}
+
+func NewCodexSource(sessionsPath string, opts Options) *CodexSource {
+	return &CodexSource{
+		SessionsPath: sessionsPath,
+		Options:      opts,
+	}
+}
+
+func (s *CodexSource) Name() string {
+	return CodexSourceName
+}
+
+func (s *CodexSource) Load() ([]models.Prompt, error) {
+	if _, err := os.Stat(s.SessionsPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: codex sessions directory does not exist: %s", ErrSourceNotFound, s.SessionsPath)
+	}
+
+	cache, err := openCache(s.Options, CodexSourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := findJSONLFiles(s.SessionsPath)
+	if err != nil {
+		return nil, fmt.Errorf("error walking codex sessions directory: %w", err)
+	}
+
+	prompts, loaded, err := readFiles(paths, cache, readCodexFile)
+	if err != nil {
+		return nil, err
+	}
+	s.loaded = loaded
+
+	saveCache(cache)
+	return prompts, nil
+}
+
+func (s *CodexSource) NewWatcher() (*Watcher, error) {
+	return newWatcher(func() ([]string, error) {
+		return findJSONLFiles(s.SessionsPath)
+	}, readCodexFile, s.loaded)
+}
+
+func readCodexFile(path string, start filePosition) ([]models.Prompt, filePosition, error) {
+	var session codexSession
+	if start.Offset > 0 {
+		var err error
+		if session, err = readCodexSession(path, start.Offset); err != nil {
+			return nil, filePosition{}, err
+		}
+	}
+
+	prompts := make([]models.Prompt, 0, 16)
+
+	end, err := scanJSONL(path, start, func(line []byte, before filePosition) bool {
+		var entry codexLine
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return false
+		}
+
+		if prompt, ok := session.apply(entry); ok {
+			prompt.File, prompt.Line = path, before.Line+1
+			prompts = append(prompts, prompt)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, filePosition{}, err
+	}
+
+	return prompts, end, nil
+}
+
+// readCodexSession recovers the session cwd and start time from the head of a
+// rollout file, so lines appended after offset can be attributed correctly.
+func readCodexSession(path string, offset int64) (codexSession, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return codexSession{}, err
+	}
+	defer file.Close()
+
+	var session codexSession
+	reader := bufio.NewReader(file)
+
+	for read := int64(0); read < offset && (session.cwd == "" || session.model == ""); {
+		line, err := reader.ReadBytes('\n')
+		read += int64(len(line))
+
+		var entry codexLine
+		if json.Unmarshal(line, &entry) == nil {
+			session.apply(entry)
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	return session, nil
+}
+
+func (s *codexSession) apply(entry codexLine) (models.Prompt, bool) {
+	item := entry.codexItem
+
+	switch entry.Type {
+	case "session_meta", "turn_context", "response_item":
+		if err := json.Unmarshal(entry.Payload, &item); err != nil {
+			return models.Prompt{}, false
+		}
+	case "message":
+		item.Type = entry.Type
+	case "":
+		if entry.ID != "" && entry.Timestamp != "" {
+			s.id, s.timestamp = entry.ID, entry.Timestamp
+		}
+		return models.Prompt{}, false
+	default:
+		return models.Prompt{}, false
+	}
+
+	switch entry.Type {
+	case "session_meta":
+		s.id, s.cwd, s.timestamp = item.ID, item.Cwd, item.Timestamp
+		s.version, s.branch = item.CLIVersion, item.Git.Branch
+		return models.Prompt{}, false
+	case "turn_context":
+		if item.Cwd != "" {
+			s.cwd = item.Cwd
+		}
+		if item.Model != "" {
+			s.model = item.Model
+		}
+		return models.Prompt{}, false
+	}
+
+	if item.Type != "message" || item.Role != "user" {
+		return models.Prompt{}, false
+	}
+
+	message := item.text()
+	if matches := codexCwdPattern.FindStringSubmatch(message); len(matches) > 1 && s.cwd == "" {
+		s.cwd = strings.TrimSpace(matches[1])
+	}
+
+	if message == "" || shouldSkipCodexMessage(message) {
+		return models.Prompt{}, false
+	}
+
+	timestamp := entry.Timestamp
+	if timestamp == "" {
+		timestamp = s.timestamp
+	}
+
+	return models.Prompt{
+		Display:   message,
+		Timestamp: parseTimestamp(timestamp),
+		Project:   s.cwd,
+		Source:    CodexSourceName,
+		SessionID: s.id,
+		GitBranch: s.branch,
+		Model:     s.model,
+		Version:   s.version,
+	}, true
+}
+
+func (item codexItem) text() string {
+	var textParts []string
+	for _, content := range item.Content {
+		if content.Type == "input_text" || content.Type == "text" {
+			textParts = append(textParts, content.Text)
+		}
+	}
+	return strings.Join(textParts, " ")
+}
+
+func shouldSkipCodexMessage(message string) bool {
+	trimmed := strings.TrimSpace(message)
+	for _, prefix := range codexSkipPrefixes {
+		if strings.HasPrefix(trimmed, prefix) {
+			return true
+		}
+	}
+	return shouldSkipMessage(message)
+}
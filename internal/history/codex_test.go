@@ -0,0 +1,147 @@
+package history
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"fpf/pkg/models"
+)
+
+func codexUserMessage(ts, text string) map[string]any {
+	return map[string]any{
+		"timestamp": ts,
+		"type":      "response_item",
+		"payload": map[string]any{
+			"type": "message",
+			"role": "user",
+			"content": []map[string]any{
+				{"type": "input_text", "text": text},
+			},
+		},
+	}
+}
+
+func TestReadCodexFile(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "2025", "06", "01", "rollout-2025-06-01T10-00-00-abc.jsonl")
+
+	writeJSONL(t, path,
+		map[string]any{
+			"timestamp": "2025-06-01T10:00:00.000Z",
+			"type":      "session_meta",
+			"payload": map[string]any{
+				"id":          "abc",
+				"timestamp":   "2025-06-01T10:00:00.000Z",
+				"cwd":         "/home/user/api",
+				"cli_version": "0.46.0",
+				"git":         map[string]any{"branch": "feature/limits"},
+			},
+		},
+		map[string]any{
+			"timestamp": "2025-06-01T10:00:00.500Z",
+			"type":      "turn_context",
+			"payload":   map[string]any{"cwd": "/home/user/api", "model": "gpt-5-codex"},
+		},
+		codexUserMessage("2025-06-01T10:00:01.000Z", "<environment_context>\n  <cwd>/home/user/api</cwd>\n</environment_context>"),
+		codexUserMessage("2025-06-01T10:00:02.000Z", "add rate limiting to the login handler"),
+		map[string]any{
+			"timestamp": "2025-06-01T10:00:03.000Z",
+			"type":      "response_item",
+			"payload": map[string]any{
+				"type":    "message",
+				"role":    "assistant",
+				"content": []map[string]any{{"type": "output_text", "text": "Done."}},
+			},
+		},
+		map[string]any{
+			"timestamp": "2025-06-01T10:00:04.000Z",
+			"type":      "event_msg",
+			"payload":   map[string]any{"type": "user_message", "message": "add rate limiting to the login handler"},
+		},
+		codexUserMessage("2025-06-01T10:00:05.000Z", "/clear"),
+	)
+
+	got, end, err := readCodexFile(path, filePosition{})
+	if err != nil {
+		t.Fatalf("readCodexFile() error = %v", err)
+	}
+
+	want := []models.Prompt{{
+		Display:   "add rate limiting to the login handler",
+		Timestamp: time.Date(2025, 6, 1, 10, 0, 2, 0, time.UTC).UnixMilli(),
+		Project:   "/home/user/api",
+		Source:    CodexSourceName,
+		SessionID: "abc",
+		GitBranch: "feature/limits",
+		Model:     "gpt-5-codex",
+		Version:   "0.46.0",
+		File:      path,
+		Line:      4,
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("readCodexFile() = %v, want %v", got, want)
+	}
+
+	appendJSONL(t, path, marshalLine(t, codexUserMessage("2025-06-01T11:00:00.000Z", "now add tests"))+"\n")
+
+	appended, _, err := readCodexFile(path, end)
+	if err != nil {
+		t.Fatalf("readCodexFile() from offset error = %v", err)
+	}
+	if len(appended) != 1 || appended[0].Display != "now add tests" || appended[0].Project != "/home/user/api" {
+		t.Errorf("readCodexFile() from offset = %v, want [now add tests] in /home/user/api", appended)
+	}
+	if len(appended) == 1 && (appended[0].Line != 8 || appended[0].Model != "gpt-5-codex") {
+		t.Errorf("readCodexFile() from offset line = %d, model = %q, want 8 and gpt-5-codex", appended[0].Line, appended[0].Model)
+	}
+}
+
+func TestReadCodexFileLegacyFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rollout-2025-04-20-legacy.jsonl")
+
+	writeJSONL(t, path,
+		map[string]any{"id": "legacy", "timestamp": "2025-04-20T09:30:00.000Z", "instructions": ""},
+		map[string]any{"record_type": "state"},
+		map[string]any{
+			"type": "message",
+			"role": "user",
+			"content": []map[string]any{
+				{"type": "input_text", "text": "<environment_context>\n<cwd>/home/user/cli</cwd>\n</environment_context>"},
+			},
+		},
+		map[string]any{
+			"type":    "message",
+			"role":    "user",
+			"content": []map[string]any{{"type": "input_text", "text": "explain the flag parser"}},
+		},
+	)
+
+	got, _, err := readCodexFile(path, filePosition{})
+	if err != nil {
+		t.Fatalf("readCodexFile() error = %v", err)
+	}
+
+	want := []models.Prompt{{
+		Display:   "explain the flag parser",
+		Timestamp: time.Date(2025, 4, 20, 9, 30, 0, 0, time.UTC).UnixMilli(),
+		Project:   "/home/user/cli",
+		Source:    CodexSourceName,
+		SessionID: "legacy",
+		File:      path,
+		Line:      4,
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readCodexFile() = %v, want %v", got, want)
+	}
+}
+
+func TestCodexSourceMissingDirectory(t *testing.T) {
+	source := NewCodexSource(filepath.Join(t.TempDir(), "missing"), Options{NoCache: true})
+
+	if _, err := source.Load(); !errors.Is(err, ErrSourceNotFound) {
+		t.Errorf("Load() error = %v, want ErrSourceNotFound", err)
+	}
+}
@@ -2,153 +2,332 @@ package history
 
 import (
 	"bufio"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"fpf/pkg/models"
 	"github.com/charlievieth/fastwalk"
 )
 
-type JSONLEntry struct {
-	Type      string  `json:"type"`
-	IsMeta    *bool   `json:"isMeta"`
-	Cwd       string  `json:"cwd"`
-	Message   Message `json:"message"`
-	UUID      string  `json:"uuid"`
-	Timestamp string  `json:"timestamp"`
+type Options struct {
+	NoCache      bool
+	RebuildCache bool
+	HistoryRoots []string
+	HistoryFiles []string
 }
 
-type Message struct {
-	Role    string      `json:"role"`
-	Content interface{} `json:"content"`
+// DedupScope is which copies of a prompt are merged into one.
+type DedupScope int
+
+const (
+	// DedupGlobal merges every copy of a prompt.
+	DedupGlobal DedupScope = iota
+	// DedupProject merges copies of a prompt sent in the same project.
+	DedupProject
+	// DedupNone keeps every copy of a prompt apart.
+	DedupNone
+)
+
+var dedupScopeNames = []string{"global", "project", "none"}
+
+func (s DedupScope) String() string {
+	if s < 0 || int(s) >= len(dedupScopeNames) {
+		return "unknown"
+	}
+	return dedupScopeNames[s]
 }
 
-func GetProjectsPath() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("failed to get home directory: %w", err)
+func ParseDedupScope(name string) (DedupScope, error) {
+	for i, scopeName := range dedupScopeNames {
+		if strings.EqualFold(name, scopeName) {
+			return DedupScope(i), nil
+		}
 	}
-	return filepath.Join(home, ".claude", "projects"), nil
+	return 0, fmt.Errorf("unknown dedup scope %q (want %s)", name, strings.Join(dedupScopeNames, ", "))
 }
 
-func ReadHistory() ([]models.Prompt, error) {
-	projectsPath, err := GetProjectsPath()
+// key returns what copies of p merged within the scope have in common, and
+// false if p is never merged. Without deduplication only copies of the same
+// occurrence, read again as its file grew, are merged.
+func (s DedupScope) key(p models.Prompt, text string) (string, bool) {
+	switch s {
+	case DedupProject:
+		return p.Project + "\x00" + text, true
+	case DedupNone:
+		if p.UUID != "" {
+			return "\x00" + p.UUID, true
+		}
+		if p.File != "" {
+			return p.File + "\x00" + strconv.Itoa(p.Line), true
+		}
+		return "", false
+	}
+	return text, true
+}
+
+func ReadHistory(sources []Source, scope DedupScope) ([]models.Prompt, error) {
+	var (
+		prompts  []models.Prompt
+		loaded   int
+		notFound error
+	)
+
+	for _, source := range sources {
+		sourcePrompts, err := source.Load()
+		if errors.Is(err, ErrSourceNotFound) {
+			if notFound == nil {
+				notFound = err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s history: %w", source.Name(), err)
+		}
+
+		loaded++
+		prompts = append(prompts, sourcePrompts...)
+	}
+
+	if loaded == 0 && notFound != nil {
+		return nil, notFound
+	}
+
+	return deduplicatePrompts(prompts, scope), nil
+}
+
+func openCache(opts Options, name string) (*Cache, error) {
+	if opts.NoCache {
+		return nil, nil
+	}
+
+	cacheDir, err := GetCacheDir()
 	if err != nil {
 		return nil, err
 	}
 
-	if _, err := os.Stat(projectsPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("projects directory does not exist: %s", projectsPath)
+	path := filepath.Join(cacheDir, name+".gob")
+	if opts.RebuildCache {
+		return NewCache(path), nil
+	}
+	return LoadCache(path)
+}
+
+func saveCache(cache *Cache) {
+	if cache == nil {
+		return
+	}
+	if err := cache.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write history cache: %v\n", err)
 	}
+}
 
-	var prompts []models.Prompt
+func findJSONLFiles(root string) ([]string, error) {
+	return findFiles(root, nil, func(name string) bool {
+		return strings.HasSuffix(name, ".jsonl")
+	})
+}
+
+func findFiles(root string, skipDirs map[string]bool, match func(name string) bool) ([]string, error) {
+	var (
+		mu    sync.Mutex
+		paths []string
+	)
 
 	conf := fastwalk.Config{
 		Follow: false,
 	}
 
-	err = fastwalk.Walk(&conf, projectsPath, func(path string, d os.DirEntry, err error) error {
+	err := fastwalk.Walk(&conf, root, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
-		if d.IsDir() || !strings.HasSuffix(path, ".jsonl") {
+		if d.IsDir() {
+			if path != root && skipDirs[d.Name()] {
+				return fastwalk.SkipDir
+			}
 			return nil
 		}
-
-		filePrompts, err := readJSONLFile(path)
-		if err != nil {
-			return err
+		if !match(d.Name()) {
+			return nil
 		}
 
-		prompts = append(prompts, filePrompts...)
+		mu.Lock()
+		paths = append(paths, path)
+		mu.Unlock()
 		return nil
 	})
-
 	if err != nil {
-		return nil, fmt.Errorf("error walking projects directory: %w", err)
+		return nil, err
 	}
 
-	return deduplicatePrompts(prompts), nil
+	sort.Strings(paths)
+	return paths, nil
 }
 
-func readJSONLFile(path string) ([]models.Prompt, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, err
+type filePosition struct {
+	Offset int64
+	Line   int
+}
+
+type fileReader func(path string, start filePosition) ([]models.Prompt, filePosition, error)
+
+// readFiles reads paths in parallel, reusing what cache already has. Along
+// with the prompts it returns how far each file was read, for a Watcher to
+// carry on from.
+func readFiles(paths []string, cache *Cache, read fileReader) ([]models.Prompt, map[string]watchedFile, error) {
+	results := make([]CachedFile, len(paths))
+	errs := make([]error, len(paths))
+
+	workers := runtime.NumCPU()
+	if workers > len(paths) {
+		workers = len(paths)
 	}
-	defer file.Close()
 
-	prompts := make([]models.Prompt, 0, 64)
-	scanner := bufio.NewScanner(file)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for range workers {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i], errs[i] = readCachedFile(paths[i], cache, read)
+			}
+		}()
+	}
 
-	const maxCapacity = 64 * 1024 * 1024
-	buf := make([]byte, maxCapacity)
-	scanner.Buffer(buf, maxCapacity)
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
 
-	for scanner.Scan() {
-		var entry JSONLEntry
-		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
-			continue
+	total := 0
+	for i, result := range results {
+		if errs[i] != nil {
+			return nil, nil, errs[i]
 		}
+		total += len(result.Prompts)
+	}
 
-		if entry.Type != "user" || entry.Message.Role != "user" {
-			continue
-		}
-		if entry.IsMeta != nil && *entry.IsMeta {
-			continue
+	prompts := make([]models.Prompt, 0, total)
+	loaded := make(map[string]watchedFile, len(paths))
+	for i, result := range results {
+		prompts = append(prompts, result.Prompts...)
+		loaded[paths[i]] = watchedFile{
+			size:      result.Size,
+			pos:       filePosition{Offset: result.Offset, Line: result.Line},
+			lineKnown: true,
 		}
+	}
 
-		message := extractMessageContent(entry.Message.Content)
-		if message == "" {
-			continue
-		}
+	cache.replace(paths, results)
+	return prompts, loaded, nil
+}
 
-		if shouldSkipMessage(message) {
-			continue
-		}
+func readCachedFile(path string, cache *Cache, read fileReader) (CachedFile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return CachedFile{}, err
+	}
 
-		timestamp := parseTimestamp(entry.Timestamp)
+	entry, ok := cache.lookup(path, info)
+	if ok && entry.Size == info.Size() {
+		return entry, nil
+	}
 
-		prompts = append(prompts, models.Prompt{
-			Display:   message,
-			Timestamp: timestamp,
-			Project:   entry.Cwd,
-		})
+	// Readers may stop short of the end of the file when the last prompts can
+	// still change, such as a reply that is still being written. Prompts past
+	// the stored position are re-read along with the new lines.
+	var cached []models.Prompt
+	var start filePosition
+	if ok {
+		start = filePosition{Offset: entry.Offset, Line: entry.Line}
+		for _, p := range entry.Prompts {
+			if p.Line <= start.Line {
+				cached = append(cached, p)
+			}
+		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: skipping file %s: %v\n", path, err)
-		return prompts, nil
+	prompts, end, err := read(path, start)
+	if err != nil {
+		return CachedFile{}, err
 	}
 
-	return prompts, nil
+	merged := make([]models.Prompt, 0, len(cached)+len(prompts))
+	merged = append(merged, cached...)
+	merged = append(merged, prompts...)
+
+	return CachedFile{
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+		Offset:  end.Offset,
+		Line:    end.Line,
+		Prompts: merged,
+	}, nil
 }
 
-func extractMessageContent(content interface{}) string {
-	switch v := content.(type) {
-	case string:
-		return v
-	case []interface{}:
-		var textParts []string
-		for _, item := range v {
-			if obj, ok := item.(map[string]interface{}); ok {
-				if typ, ok := obj["type"].(string); ok && typ == "text" {
-					if text, ok := obj["text"].(string); ok {
-						textParts = append(textParts, text)
-					}
-				}
-			}
+// scanJSONL calls handle for each line of path from start onwards, passing
+// the position just before the line, so the line's 1-based number is
+// before.Line+1. handle reports whether the line was valid JSON. Only lines
+// terminated by a newline (or a valid trailing JSON line) are counted towards
+// the returned position, so a line that is still being written is picked up
+// in full on the next read.
+func scanJSONL(path string, start filePosition, handle func(line []byte, before filePosition) bool) (filePosition, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return filePosition{}, err
+	}
+	defer file.Close()
+
+	if start.Offset > 0 {
+		if _, err := file.Seek(start.Offset, io.SeekStart); err != nil {
+			return filePosition{}, err
+		}
+	}
+
+	scanner := bufio.NewScanner(file)
+
+	const initialCapacity = 64 * 1024
+	const maxCapacity = 64 * 1024 * 1024
+	scanner.Buffer(make([]byte, 0, initialCapacity), maxCapacity)
+
+	consumed, end := start.Offset, start
+	lineNo := start.Line
+	lineStart := start.Offset
+	terminated := false
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		consumed += int64(advance)
+		terminated = advance > len(token)
+		return advance, token, err
+	})
+
+	for scanner.Scan() {
+		before := filePosition{Offset: lineStart, Line: lineNo}
+		lineNo++
+		lineStart = consumed
+		if handle(scanner.Bytes(), before) || terminated {
+			end = filePosition{Offset: consumed, Line: lineNo}
 		}
-		return strings.Join(textParts, " ")
-	default:
-		return ""
 	}
+
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: skipping file %s: %v\n", path, err)
+	}
+
+	return end, nil
 }
 
 var skipPrefixes = []string{
@@ -183,21 +362,29 @@ func parseTimestamp(timestamp string) int64 {
 	return t.UnixMilli()
 }
 
-func deduplicatePrompts(prompts []models.Prompt) []models.Prompt {
-	seen := make(map[string]models.Prompt)
+// deduplicatePrompts merges the copies of each prompt within scope, keeping
+// the newest copy's details along with every copy's occurrence.
+func deduplicatePrompts(prompts []models.Prompt, scope DedupScope) []models.Prompt {
+	seen := make(map[string]int, len(prompts))
+	result := make([]models.Prompt, 0, len(prompts))
 
 	for _, p := range prompts {
-		if existing, exists := seen[p.Display]; !exists || p.Timestamp > existing.Timestamp {
-			seen[p.Display] = p
+		key, ok := scope.key(p, p.Display)
+		idx, exists := seen[key]
+		if !ok || !exists {
+			if ok {
+				seen[key] = len(result)
+			}
+			p.Uses = p.UseCount()
+			p.Occurrences = slices.Clip(p.Occurrences)
+			result = append(result, p)
+			continue
 		}
-	}
 
-	result := make([]models.Prompt, 0, len(seen))
-	for _, p := range seen {
-		result = append(result, p)
+		result[idx].Absorb(p)
 	}
 
-	sort.Slice(result, func(i, j int) bool {
+	sort.SliceStable(result, func(i, j int) bool {
 		return result[i].Timestamp > result[j].Timestamp
 	})
 
@@ -0,0 +1,335 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"fpf/pkg/models"
+)
+
+func writeJSONL(t *testing.T, path string, lines ...any) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	var b strings.Builder
+	for _, line := range lines {
+		data, err := json.Marshal(line)
+		if err != nil {
+			t.Fatalf("Failed to marshal line: %v", err)
+		}
+		b.Write(data)
+		b.WriteString("\n")
+	}
+
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func userEntry(text, cwd string, ts time.Time) map[string]any {
+	return map[string]any{
+		"type":      "user",
+		"cwd":       cwd,
+		"timestamp": ts.UTC().Format(time.RFC3339),
+		"message": map[string]any{
+			"role":    "user",
+			"content": text,
+		},
+	}
+}
+
+func buildFixtureTree(t *testing.T, projects, files, linesPerFile int) string {
+	t.Helper()
+
+	root := t.TempDir()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for p := range projects {
+		cwd := fmt.Sprintf("/home/user/project-%d", p)
+		dir := filepath.Join(root, fmt.Sprintf("-home-user-project-%d", p))
+		for f := range files {
+			lines := make([]any, 0, linesPerFile+1)
+			lines = append(lines, map[string]any{"type": "summary"})
+			for l := range linesPerFile {
+				ts := base.Add(time.Duration(p*files*linesPerFile+f*linesPerFile+l) * time.Minute)
+				lines = append(lines, userEntry(fmt.Sprintf("prompt %d/%d/%d", p, f, l), cwd, ts))
+			}
+			writeJSONL(t, filepath.Join(dir, fmt.Sprintf("session-%d.jsonl", f)), lines...)
+		}
+	}
+
+	return root
+}
+
+func TestReadProjectsParallel(t *testing.T) {
+	const projects, files, linesPerFile = 8, 12, 25
+	root := buildFixtureTree(t, projects, files, linesPerFile)
+
+	paths, err := findJSONLFiles(root)
+	if err != nil {
+		t.Fatalf("findJSONLFiles() error = %v", err)
+	}
+	if len(paths) != projects*files {
+		t.Fatalf("findJSONLFiles() found %d files, want %d", len(paths), projects*files)
+	}
+
+	var sequential []models.Prompt
+	for _, path := range paths {
+		filePrompts, _, err := readJSONLFile(path, filePosition{})
+		if err != nil {
+			t.Fatalf("readJSONLFile(%s) error = %v", path, err)
+		}
+		sequential = append(sequential, filePrompts...)
+	}
+
+	for run := range 5 {
+		got, _, err := readProjects(root, nil)
+		if err != nil {
+			t.Fatalf("readProjects() error = %v", err)
+		}
+		if len(got) != projects*files*linesPerFile {
+			t.Fatalf("run %d: readProjects() returned %d prompts, want %d", run, len(got), projects*files*linesPerFile)
+		}
+		if !reflect.DeepEqual(got, sequential) {
+			t.Fatalf("run %d: readProjects() result differs from sequential read", run)
+		}
+	}
+}
+
+func TestReadProjectsMissingFileFails(t *testing.T) {
+	root := buildFixtureTree(t, 2, 2, 1)
+
+	if _, _, err := readFiles([]string{filepath.Join(root, "missing.jsonl")}, nil, readJSONLFile); err == nil {
+		t.Error("readFiles() with missing file returned nil error")
+	}
+}
+
+func TestDeduplicatePrompts(t *testing.T) {
+	prompts := []models.Prompt{
+		{Display: "a", Timestamp: 1, Project: "/one"},
+		{Display: "b", Timestamp: 5, Project: "/one"},
+		{Display: "a", Timestamp: 3, Project: "/two"},
+		{Display: "c", Timestamp: 5, Project: "/two"},
+	}
+
+	tests := []struct {
+		scope DedupScope
+		want  []models.Prompt
+	}{
+		{DedupGlobal, []models.Prompt{
+			{Display: "b", Timestamp: 5, Project: "/one", Uses: 1},
+			{Display: "c", Timestamp: 5, Project: "/two", Uses: 1},
+			{Display: "a", Timestamp: 3, Project: "/two", Uses: 2, Occurrences: []models.Occurrence{
+				{Timestamp: 1, Project: "/one"},
+				{Timestamp: 3, Project: "/two"},
+			}},
+		}},
+		{DedupProject, []models.Prompt{
+			{Display: "b", Timestamp: 5, Project: "/one", Uses: 1},
+			{Display: "c", Timestamp: 5, Project: "/two", Uses: 1},
+			{Display: "a", Timestamp: 3, Project: "/two", Uses: 1},
+			{Display: "a", Timestamp: 1, Project: "/one", Uses: 1},
+		}},
+		{DedupNone, []models.Prompt{
+			{Display: "b", Timestamp: 5, Project: "/one", Uses: 1},
+			{Display: "c", Timestamp: 5, Project: "/two", Uses: 1},
+			{Display: "a", Timestamp: 3, Project: "/two", Uses: 1},
+			{Display: "a", Timestamp: 1, Project: "/one", Uses: 1},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scope.String(), func(t *testing.T) {
+			got := deduplicatePrompts(prompts, tt.scope)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("deduplicatePrompts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	prompts = append(prompts, models.Prompt{Display: "a", Timestamp: 2, Project: "/one"})
+	if got := deduplicatePrompts(prompts, DedupProject); len(got) != 4 || got[3].Uses != 2 {
+		t.Errorf("deduplicatePrompts() per project = %v, want the /one copies of a merged", got)
+	}
+
+	reread := []models.Prompt{
+		{Display: "a", Timestamp: 1, File: "s.jsonl", Line: 1},
+		{Display: "a", Timestamp: 2, File: "s.jsonl", Line: 3},
+		{Display: "a", Timestamp: 1, File: "s.jsonl", Line: 1, Replies: []string{"Done."}},
+	}
+	if got := deduplicatePrompts(reread, DedupNone); len(got) != 2 || got[1].Uses != 1 || got[1].FirstReply() != "Done." {
+		t.Errorf("deduplicatePrompts() without dedup = %v, want the re-read copy of line 1 merged", got)
+	}
+}
+
+func TestParseDedupScope(t *testing.T) {
+	for _, scope := range []DedupScope{DedupGlobal, DedupProject, DedupNone} {
+		if got, err := ParseDedupScope(scope.String()); err != nil || got != scope {
+			t.Errorf("ParseDedupScope(%q) = %v, %v", scope, got, err)
+		}
+	}
+	if _, err := ParseDedupScope("session"); err == nil {
+		t.Error("ParseDedupScope(\"session\") returned nil error")
+	}
+}
+
+type fakeSource struct {
+	name    string
+	prompts []models.Prompt
+	err     error
+}
+
+func (s fakeSource) Name() string                   { return s.name }
+func (s fakeSource) Load() ([]models.Prompt, error) { return s.prompts, s.err }
+
+func TestReadHistorySources(t *testing.T) {
+	claude := fakeSource{name: "claude", prompts: []models.Prompt{
+		{Display: "shared", Timestamp: 1, Source: "claude"},
+		{Display: "only claude", Timestamp: 2, Source: "claude"},
+	}}
+	codex := fakeSource{name: "codex", prompts: []models.Prompt{
+		{Display: "shared", Timestamp: 3, Source: "codex"},
+	}}
+	missing := fakeSource{name: "missing", err: fmt.Errorf("%w: nowhere", ErrSourceNotFound)}
+
+	got, err := ReadHistory([]Source{claude, missing, codex}, DedupGlobal)
+	if err != nil {
+		t.Fatalf("ReadHistory() error = %v", err)
+	}
+
+	want := []models.Prompt{
+		{Display: "shared", Timestamp: 3, Source: "codex", Uses: 2, Occurrences: []models.Occurrence{{Timestamp: 1}, {Timestamp: 3}}},
+		{Display: "only claude", Timestamp: 2, Source: "claude", Uses: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadHistory() = %v, want %v", got, want)
+	}
+
+	if _, err := ReadHistory([]Source{missing}, DedupGlobal); !errors.Is(err, ErrSourceNotFound) {
+		t.Errorf("ReadHistory() with only missing sources error = %v, want ErrSourceNotFound", err)
+	}
+
+	broken := fakeSource{name: "broken", err: errors.New("boom")}
+	if _, err := ReadHistory([]Source{claude, broken}, DedupGlobal); err == nil {
+		t.Error("ReadHistory() with failing source returned nil error")
+	}
+}
+
+func TestReadJSONLFileMetadata(t *testing.T) {
+	ts := time.Date(2025, 2, 3, 4, 5, 6, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+
+	prompt := userEntry("add a health check", "/home/user/api", ts)
+	prompt["sessionId"] = "session-1"
+	prompt["uuid"] = "u-1"
+	prompt["parentUuid"] = "p-0"
+	prompt["gitBranch"] = "main"
+	prompt["version"] = "1.0.80"
+	prompt["isSidechain"] = true
+
+	writeJSONL(t, path,
+		map[string]any{"type": "summary"},
+		prompt,
+		map[string]any{"type": "assistant", "uuid": "a-1", "parentUuid": "u-1", "message": map[string]any{"role": "assistant", "model": "<synthetic>"}},
+		map[string]any{"type": "assistant", "uuid": "a-2", "parentUuid": "a-1", "message": map[string]any{"role": "assistant", "model": "claude-sonnet-4-5"}},
+	)
+
+	got, end, err := readJSONLFile(path, filePosition{})
+	if err != nil {
+		t.Fatalf("readJSONLFile() error = %v", err)
+	}
+
+	want := []models.Prompt{{
+		Display:     "add a health check",
+		Timestamp:   ts.UnixMilli(),
+		Project:     "/home/user/api",
+		Source:      ClaudeSourceName,
+		SessionID:   "session-1",
+		UUID:        "u-1",
+		ParentUUID:  "p-0",
+		GitBranch:   "main",
+		Model:       "claude-sonnet-4-5",
+		Version:     "1.0.80",
+		IsSidechain: true,
+		File:        path,
+		Line:        2,
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readJSONLFile() = %+v, want %+v", got, want)
+	}
+	if end.Line != 1 {
+		t.Errorf("readJSONLFile() end line = %d, want 1 so the last prompt's reply is re-read", end.Line)
+	}
+}
+
+func chained(entry map[string]any, uuid, parent string) map[string]any {
+	entry["uuid"] = uuid
+	if parent != "" {
+		entry["parentUuid"] = parent
+	}
+	return entry
+}
+
+func TestReadJSONLFileLinksReplies(t *testing.T) {
+	ts := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+
+	writeJSONL(t, path,
+		chained(userEntry("fix the login bug", "/app", ts), "u1", ""),
+		chained(assistantEntry(map[string]any{"type": "text", "text": "Looking at the handler."}), "a1", "u1"),
+		chained(assistantEntry(toolUse("Edit", map[string]any{"file_path": "/app/login.go"})), "a2", "a1"),
+		chained(toolResult(), "r1", "a2"),
+		chained(assistantEntry(toolUse("Bash", map[string]any{"command": "go test ./..."})), "a3", "r1"),
+		chained(toolResult(), "r2", "a3"),
+		chained(assistantEntry(map[string]any{"type": "text", "text": "Fixed."}), "a4", "r2"),
+		chained(userEntry("now add a test", "/app", ts), "u2", "a4"),
+	)
+
+	cache := NewCache(filepath.Join(t.TempDir(), "history.gob"))
+	entry, err := readCachedFile(path, cache, readJSONLFile)
+	if err != nil {
+		t.Fatalf("readCachedFile() error = %v", err)
+	}
+	if len(entry.Prompts) != 2 {
+		t.Fatalf("readCachedFile() returned %d prompts, want 2", len(entry.Prompts))
+	}
+
+	first := entry.Prompts[0]
+	if want := []string{"Looking at the handler.", "Fixed."}; !reflect.DeepEqual(first.Replies, want) {
+		t.Errorf("first prompt replies = %q, want %q", first.Replies, want)
+	}
+	wantTools := []models.ToolCall{
+		{Name: "Edit", Summary: "/app/login.go", FilePath: "/app/login.go"},
+		{Name: "Bash", Summary: "go test ./..."},
+	}
+	if !reflect.DeepEqual(first.Tools, wantTools) {
+		t.Errorf("first prompt tools = %+v, want %+v", first.Tools, wantTools)
+	}
+	if len(entry.Prompts[1].Replies) != 0 || len(entry.Prompts[1].Tools) != 0 {
+		t.Errorf("second prompt has reply before one was written: %+v", entry.Prompts[1])
+	}
+	cache.Files[path] = entry
+
+	appendJSONL(t, path, marshalLine(t, chained(assistantEntry(map[string]any{"type": "text", "text": "Added TestLogin."}), "a5", "u2"))+"\n")
+
+	entry, err = readCachedFile(path, cache, readJSONLFile)
+	if err != nil {
+		t.Fatalf("readCachedFile() after append error = %v", err)
+	}
+	if len(entry.Prompts) != 2 {
+		t.Fatalf("readCachedFile() after append returned %d prompts, want 2", len(entry.Prompts))
+	}
+	if entry.Prompts[0].FirstReply() != "Looking at the handler." || entry.Prompts[1].FirstReply() != "Added TestLogin." {
+		t.Errorf("replies after append = %q, %q", entry.Prompts[0].Replies, entry.Prompts[1].Replies)
+	}
+}
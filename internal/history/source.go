@@ -0,0 +1,94 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"fpf/pkg/models"
+)
+
+var ErrSourceNotFound = errors.New("history source not found")
+
+type Source interface {
+	Name() string
+	Load() ([]models.Prompt, error)
+}
+
+type WatchableSource interface {
+	Source
+	NewWatcher() (*Watcher, error)
+}
+
+type SourceFactory func(opts Options) (Source, error)
+
+var (
+	registry      = make(map[string]SourceFactory)
+	registryOrder []string
+)
+
+func RegisterSource(name string, factory SourceFactory) {
+	if _, exists := registry[name]; !exists {
+		registryOrder = append(registryOrder, name)
+	}
+	registry[name] = factory
+}
+
+func SourceNames() []string {
+	return append([]string(nil), registryOrder...)
+}
+
+func NewSources(names []string, opts Options) ([]Source, error) {
+	sources := make([]Source, 0, len(names))
+	for _, name := range names {
+		factory, ok := registry[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown history source: %s", name)
+		}
+
+		source, err := factory(opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s source: %w", name, err)
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+func Watch(ctx context.Context, sources []Source, interval time.Duration, onPrompts func([]models.Prompt)) {
+	var watchers []*Watcher
+	for _, source := range sources {
+		ws, ok := source.(WatchableSource)
+		if !ok {
+			continue
+		}
+		if w, err := ws.NewWatcher(); err == nil {
+			watchers = append(watchers, w)
+		}
+	}
+
+	if len(watchers) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			var prompts []models.Prompt
+			for _, w := range watchers {
+				if polled, err := w.Poll(); err == nil {
+					prompts = append(prompts, polled...)
+				}
+			}
+			if len(prompts) > 0 {
+				onPrompts(prompts)
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"fpf/pkg/models"
+)
+
+const StoreSourceName = "imports"
+
+func init() {
+	RegisterSource(StoreSourceName, func(opts Options) (Source, error) {
+		store, err := OpenStore()
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	})
+}
+
+func GetDataDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "fpf"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", "fpf"), nil
+}
+
+// Store holds prompts imported from outside sources. Each import kind is kept
+// in its own JSONL file, so re-importing an export replaces the previous copy.
+type Store struct {
+	Dir string
+}
+
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+func OpenStore() (*Store, error) {
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewStore(filepath.Join(dataDir, "imports")), nil
+}
+
+func (s *Store) Name() string {
+	return StoreSourceName
+}
+
+func (s *Store) Load() ([]models.Prompt, error) {
+	if _, err := os.Stat(s.Dir); os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: no imported prompts in %s", ErrSourceNotFound, s.Dir)
+	}
+
+	paths, err := filepath.Glob(filepath.Join(s.Dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var prompts []models.Prompt
+	for _, path := range paths {
+		_, err := scanJSONL(path, filePosition{}, func(line []byte, _ filePosition) bool {
+			var prompt models.Prompt
+			if err := json.Unmarshal(line, &prompt); err != nil {
+				return false
+			}
+			prompts = append(prompts, prompt)
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return prompts, nil
+}
+
+func (s *Store) Replace(name string, prompts []models.Prompt) error {
+	if strings.ContainsAny(name, `/\`) || name == "" {
+		return fmt.Errorf("invalid store name: %q", name)
+	}
+
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.Dir, name+".jsonl")
+	tmp, err := os.CreateTemp(s.Dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	encoder := json.NewEncoder(tmp)
+	encoder.SetEscapeHTML(false)
+	for _, prompt := range prompts {
+		if err := encoder.Encode(prompt); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,44 @@
+package history
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"fpf/pkg/models"
+)
+
+func TestStoreReplaceAndLoad(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "imports"))
+
+	if _, err := store.Load(); !errors.Is(err, ErrSourceNotFound) {
+		t.Errorf("Load() of empty store error = %v, want ErrSourceNotFound", err)
+	}
+
+	first := []models.Prompt{
+		{Display: "old prompt", Timestamp: 1, Project: "claude.ai/Chat", Source: "claude.ai"},
+	}
+	second := []models.Prompt{
+		{Display: "new prompt <b>", Timestamp: 2, Project: "claude.ai/Chat", Source: "claude.ai"},
+	}
+
+	if err := store.Replace("claude-export", first); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	if err := store.Replace("claude-export", second); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+
+	got, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, second) {
+		t.Errorf("Load() = %v, want %v", got, second)
+	}
+
+	if err := store.Replace("../escape", second); err == nil {
+		t.Error("Replace() with path separator returned nil error")
+	}
+}
@@ -0,0 +1,150 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"fpf/pkg/models"
+)
+
+const maxToolSummaryLength = 120
+
+var toolSummaryKeys = []string{"file_path", "notebook_path", "path", "command", "pattern", "url", "query", "description", "prompt"}
+
+// ReadTranscript re-reads the Claude Code session file a prompt came from and
+// returns its user and assistant turns in order. Consecutive assistant entries
+// are folded into a single turn, and tool results are dropped.
+func ReadTranscript(prompt models.Prompt) ([]models.Turn, error) {
+	if prompt.Source != ClaudeSourceName || prompt.File == "" {
+		return nil, fmt.Errorf("no session transcript available for %s prompts", prompt.Source)
+	}
+
+	var turns []models.Turn
+	_, err := scanJSONL(prompt.File, filePosition{}, func(line []byte, before filePosition) bool {
+		var entry JSONLEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return false
+		}
+
+		turns = entry.appendTurn(turns, before.Line+1)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return turns, nil
+}
+
+func (entry JSONLEntry) appendTurn(turns []models.Turn, lineNo int) []models.Turn {
+	switch entry.Type {
+	case "user":
+		if _, ok := entry.prompt(); !ok {
+			return turns
+		}
+		return append(turns, models.Turn{
+			Role:      models.RoleUser,
+			Text:      extractMessageContent(entry.Message.Content),
+			UUID:      entry.UUID,
+			Timestamp: parseTimestamp(entry.Timestamp),
+			Line:      lineNo,
+		})
+
+	case "assistant":
+		text, tools := extractAssistantContent(entry.Message.Content)
+		if text == "" && len(tools) == 0 {
+			return turns
+		}
+
+		if n := len(turns); n > 0 && turns[n-1].Role == models.RoleAssistant {
+			last := &turns[n-1]
+			if text != "" {
+				if last.Text != "" {
+					last.Text += "\n\n"
+				}
+				last.Text += text
+			}
+			last.Tools = append(last.Tools, tools...)
+			return turns
+		}
+
+		return append(turns, models.Turn{
+			Role:      models.RoleAssistant,
+			Text:      text,
+			Tools:     tools,
+			UUID:      entry.UUID,
+			Timestamp: parseTimestamp(entry.Timestamp),
+			Line:      lineNo,
+		})
+	}
+
+	return turns
+}
+
+func extractAssistantContent(content interface{}) (string, []models.ToolCall) {
+	items, ok := content.([]interface{})
+	if !ok {
+		if text, ok := content.(string); ok {
+			return text, nil
+		}
+		return "", nil
+	}
+
+	var (
+		textParts []string
+		tools     []models.ToolCall
+	)
+	for _, item := range items {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		switch obj["type"] {
+		case "text":
+			if text, ok := obj["text"].(string); ok && strings.TrimSpace(text) != "" {
+				textParts = append(textParts, text)
+			}
+		case "tool_use":
+			name, _ := obj["name"].(string)
+			input, _ := obj["input"].(map[string]interface{})
+			tools = append(tools, newToolCall(name, input))
+		}
+	}
+
+	return strings.Join(textParts, "\n\n"), tools
+}
+
+func newToolCall(name string, input map[string]interface{}) models.ToolCall {
+	call := models.ToolCall{Name: name}
+
+	for _, key := range []string{"file_path", "notebook_path"} {
+		if path, ok := input[key].(string); ok && path != "" {
+			call.FilePath = path
+			break
+		}
+	}
+
+	for _, key := range toolSummaryKeys {
+		if value, ok := input[key].(string); ok && strings.TrimSpace(value) != "" {
+			call.Summary = truncateSummary(value)
+			break
+		}
+	}
+
+	return call
+}
+
+func truncateSummary(s string) string {
+	s = strings.TrimSpace(s)
+	if idx := strings.IndexByte(s, '\n'); idx >= 0 {
+		s = s[:idx] + "…"
+	}
+
+	runes := []rune(s)
+	if len(runes) > maxToolSummaryLength {
+		return string(runes[:maxToolSummaryLength-1]) + "…"
+	}
+	return s
+}
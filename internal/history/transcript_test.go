@@ -0,0 +1,85 @@
+package history
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"fpf/pkg/models"
+)
+
+func assistantEntry(content ...map[string]any) map[string]any {
+	return map[string]any{
+		"type":      "assistant",
+		"timestamp": "2025-01-01T00:00:01Z",
+		"message": map[string]any{
+			"role":    "assistant",
+			"model":   "claude-sonnet-4-5",
+			"content": content,
+		},
+	}
+}
+
+func toolUse(name string, input map[string]any) map[string]any {
+	return map[string]any{"type": "tool_use", "name": name, "input": input}
+}
+
+func toolResult() map[string]any {
+	return map[string]any{
+		"type":      "user",
+		"timestamp": "2025-01-01T00:00:02Z",
+		"message": map[string]any{
+			"role":    "user",
+			"content": []map[string]any{{"type": "tool_result", "content": "ok"}},
+		},
+	}
+}
+
+func TestReadTranscript(t *testing.T) {
+	ts := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+
+	writeJSONL(t, path,
+		map[string]any{"type": "summary"},
+		userEntry("fix the login bug", "/app", ts),
+		assistantEntry(map[string]any{"type": "text", "text": "Looking at the handler."}),
+		assistantEntry(toolUse("Edit", map[string]any{"file_path": "/app/login.go", "old_string": "a"})),
+		toolResult(),
+		assistantEntry(toolUse("Bash", map[string]any{"command": "go test ./...\ngo vet ./..."})),
+		toolResult(),
+		assistantEntry(map[string]any{"type": "text", "text": "Fixed."}),
+		userEntry("<command-name>/clear</command-name>", "/app", ts),
+		userEntry("now add a test", "/app", ts),
+	)
+
+	got, err := ReadTranscript(models.Prompt{Source: ClaudeSourceName, File: path})
+	if err != nil {
+		t.Fatalf("ReadTranscript() error = %v", err)
+	}
+
+	want := []models.Turn{
+		{Role: models.RoleUser, Text: "fix the login bug", Timestamp: ts.UnixMilli(), Line: 2},
+		{
+			Role: models.RoleAssistant,
+			Text: "Looking at the handler.\n\nFixed.",
+			Tools: []models.ToolCall{
+				{Name: "Edit", Summary: "/app/login.go", FilePath: "/app/login.go"},
+				{Name: "Bash", Summary: "go test ./...…"},
+			},
+			Timestamp: ts.Add(time.Second).UnixMilli(),
+			Line:      3,
+		},
+		{Role: models.RoleUser, Text: "now add a test", Timestamp: ts.UnixMilli(), Line: 10},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadTranscript() = %+v, want %+v", got, want)
+	}
+}
+
+func TestReadTranscriptUnsupportedSource(t *testing.T) {
+	if _, err := ReadTranscript(models.Prompt{Source: CodexSourceName, File: "/tmp/x.jsonl"}); err == nil {
+		t.Error("ReadTranscript() for codex prompt returned nil error")
+	}
+}
@@ -0,0 +1,155 @@
+package history
+
+import (
+	"bytes"
+	"io"
+	"maps"
+	"os"
+	"time"
+
+	"fpf/pkg/models"
+)
+
+const DefaultPollInterval = 2 * time.Second
+
+type watchedFile struct {
+	size      int64
+	pos       filePosition
+	lineKnown bool
+}
+
+type Watcher struct {
+	find  func() ([]string, error)
+	read  fileReader
+	files map[string]watchedFile
+}
+
+// newWatcher carries on from where loading the files stopped, as recorded in
+// loaded, so nothing written since is missed. Files not in loaded are new
+// and are read from the start. Without loaded, the watcher starts at the end
+// of the files found now.
+func newWatcher(find func() ([]string, error), read fileReader, loaded map[string]watchedFile) (*Watcher, error) {
+	if loaded != nil {
+		return &Watcher{find: find, read: read, files: maps.Clone(loaded)}, nil
+	}
+
+	paths, err := find()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		find:  find,
+		read:  read,
+		files: make(map[string]watchedFile, len(paths)),
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		offset, err := lastLineEnd(path, info.Size())
+		if err != nil {
+			continue
+		}
+		w.files[path] = watchedFile{size: info.Size(), pos: filePosition{Offset: offset}}
+	}
+
+	return w, nil
+}
+
+// Poll returns the prompts written since the previous poll. Files that shrank
+// are re-read from the start, so callers should expect to see some prompts
+// more than once.
+func (w *Watcher) Poll() ([]models.Prompt, error) {
+	paths, err := w.find()
+	if err != nil {
+		return nil, err
+	}
+
+	var prompts []models.Prompt
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		state, known := w.files[path]
+		if known && info.Size() == state.size {
+			continue
+		}
+
+		var start filePosition
+		if known && info.Size() > state.size {
+			start = state.pos
+			if !state.lineKnown {
+				if start.Line, err = countLines(path, start.Offset); err != nil {
+					continue
+				}
+			}
+		}
+
+		filePrompts, end, err := w.read(path, start)
+		if err != nil {
+			continue
+		}
+
+		w.files[path] = watchedFile{size: info.Size(), pos: end, lineKnown: true}
+		prompts = append(prompts, filePrompts...)
+	}
+
+	return prompts, nil
+}
+
+// lastLineEnd returns the offset just past the final newline in the first
+// size bytes of path, so a line still being written when the watcher starts
+// is read in full once it is complete.
+func lastLineEnd(path string, size int64) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	const chunkSize = 4096
+	buf := make([]byte, chunkSize)
+
+	for end := size; end > 0; {
+		start := max(end-chunkSize, 0)
+		n, err := file.ReadAt(buf[:end-start], start)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		if idx := bytes.LastIndexByte(buf[:n], '\n'); idx >= 0 {
+			return start + int64(idx) + 1, nil
+		}
+		end = start
+	}
+
+	return 0, nil
+}
+
+func countLines(path string, offset int64) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	lines := 0
+	buf := make([]byte, 64*1024)
+	reader := io.LimitReader(file, offset)
+	for {
+		n, err := reader.Read(buf)
+		lines += bytes.Count(buf[:n], []byte{'\n'})
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package history
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func pollDisplays(t *testing.T, w *Watcher) []string {
+	t.Helper()
+
+	prompts, err := w.Poll()
+	if err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+
+	result := make([]string, len(prompts))
+	for i, p := range prompts {
+		result[i] = p.Display
+	}
+	return result
+}
+
+func TestWatcherPoll(t *testing.T) {
+	ts := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	root := t.TempDir()
+	path := filepath.Join(root, "-home-user-app", "session.jsonl")
+	writeJSONL(t, path, userEntry("existing", "/home/user/app", ts))
+
+	pending := marshalLine(t, userEntry("in flight", "/home/user/app", ts))
+	appendJSONL(t, path, pending[:10])
+
+	w, err := newWatcher(func() ([]string, error) { return findJSONLFiles(root) }, readJSONLFile, nil)
+	if err != nil {
+		t.Fatalf("newWatcher() error = %v", err)
+	}
+
+	if got := pollDisplays(t, w); len(got) != 0 {
+		t.Errorf("Poll() without changes = %v, want none", got)
+	}
+
+	appendJSONL(t, path, pending[10:]+"\n"+marshalLine(t, userEntry("appended", "/home/user/app", ts))+"\n")
+	prompts, err := w.Poll()
+	if err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+	if len(prompts) != 2 || prompts[0].Display != "in flight" || prompts[1].Display != "appended" {
+		t.Fatalf("Poll() after append = %v, want [in flight appended]", prompts)
+	}
+	if prompts[0].Line != 2 || prompts[1].Line != 3 {
+		t.Errorf("Poll() after append lines = %d, %d, want 2, 3", prompts[0].Line, prompts[1].Line)
+	}
+
+	other := filepath.Join(root, "-home-user-other", "new.jsonl")
+	writeJSONL(t, other, userEntry("new session", "/home/user/other", ts))
+	if got := pollDisplays(t, w); len(got) != 1 || got[0] != "new session" {
+		t.Errorf("Poll() after new file = %v, want [new session]", got)
+	}
+
+	if got := pollDisplays(t, w); len(got) != 0 {
+		t.Errorf("Poll() after no further changes = %v, want none", got)
+	}
+}
+
+func TestLastLineEnd(t *testing.T) {
+	ts := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	writeJSONL(t, path, userEntry("one", "/p", ts))
+
+	line := marshalLine(t, userEntry("one", "/p", ts))
+	size := int64(len(line) + 1)
+
+	if got, err := lastLineEnd(path, size); err != nil || got != size {
+		t.Errorf("lastLineEnd() = %d, %v, want %d", got, err, size)
+	}
+
+	appendJSONL(t, path, "{\"partial")
+	if got, err := lastLineEnd(path, size+9); err != nil || got != size {
+		t.Errorf("lastLineEnd() with partial line = %d, %v, want %d", got, err, size)
+	}
+}
+
+func TestWatcherCarriesOnFromLoad(t *testing.T) {
+	ts := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	root := t.TempDir()
+	path := filepath.Join(root, "-home-user-app", "session.jsonl")
+
+	entry := func(text, uuid, parent string) map[string]any {
+		e := userEntry(text, "/home/user/app", ts)
+		e["uuid"], e["parentUuid"] = uuid, parent
+		return e
+	}
+	reply := func(text, uuid, parent string) string {
+		e := assistantEntry(map[string]any{"type": "text", "text": text})
+		e["uuid"], e["parentUuid"] = uuid, parent
+		return marshalLine(t, e) + "\n"
+	}
+
+	writeJSONL(t, path, entry("first", "u1", ""))
+	appendJSONL(t, path, reply("Done.", "a1", "u1"))
+	appendJSONL(t, path, marshalLine(t, entry("second", "u2", "a1"))+"\n")
+
+	source := NewClaudeSource(root, Options{NoCache: true})
+	if prompts, err := source.Load(); err != nil || len(prompts) != 2 {
+		t.Fatalf("Load() = %d prompts, %v, want 2", len(prompts), err)
+	}
+
+	// The reply to the last prompt and another prompt arrive before the
+	// watcher starts.
+	appendJSONL(t, path, reply("Working on it.", "a2", "u2"))
+	appendJSONL(t, path, marshalLine(t, entry("during startup", "u3", "a2"))+"\n")
+
+	w, err := source.NewWatcher()
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+	prompts, err := w.Poll()
+	if err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+	if len(prompts) != 2 || prompts[0].Display != "second" || prompts[1].Display != "during startup" {
+		t.Fatalf("Poll() = %v, want [second during startup]", prompts)
+	}
+	if got := prompts[0].Replies; len(got) != 1 || got[0] != "Working on it." {
+		t.Errorf("Poll() replies to second = %q, want [Working on it.]", got)
+	}
+	if prompts[1].Line != 5 {
+		t.Errorf("Poll() line of during startup = %d, want 5", prompts[1].Line)
+	}
+}
+
+func TestWatcherStreamingReply(t *testing.T) {
+	for _, scope := range []DedupScope{DedupGlobal, DedupProject, DedupNone} {
+		t.Run(scope.String(), func(t *testing.T) {
+			ts := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+			root := t.TempDir()
+			path := filepath.Join(root, "-home-user-app", "session.jsonl")
+
+			prompt := userEntry("deploy the api", "/home/user/app", ts)
+			prompt["uuid"] = "u1"
+			writeJSONL(t, path, prompt)
+
+			source := NewClaudeSource(root, Options{NoCache: true})
+			prompts, err := source.Load()
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			g := NewGrouper(scope)
+			shown := g.Group(prompts)
+
+			w, err := source.NewWatcher()
+			if err != nil {
+				t.Fatalf("NewWatcher() error = %v", err)
+			}
+
+			parent := "u1"
+			for i, text := range []string{"Building.", "Uploading.", "Deployed."} {
+				e := assistantEntry(map[string]any{"type": "text", "text": text})
+				e["uuid"], e["parentUuid"] = fmt.Sprintf("a%d", i), parent
+				parent = e["uuid"].(string)
+				appendJSONL(t, path, marshalLine(t, e)+"\n")
+
+				polled, err := w.Poll()
+				if err != nil {
+					t.Fatalf("Poll() error = %v", err)
+				}
+				shown = g.Merge(shown, polled)
+			}
+
+			if len(shown) != 1 {
+				t.Fatalf("merged prompts = %d, want 1", len(shown))
+			}
+			if got := shown[0].UseCount(); got != 1 {
+				t.Errorf("UseCount() = %d, want 1 for a prompt re-read while its reply streams in", got)
+			}
+			if got := shown[0].Timeline(); len(got) != 1 {
+				t.Errorf("Timeline() = %v, want a single occurrence", got)
+			}
+			if got := shown[0].Replies; len(got) != 3 || got[2] != "Deployed." {
+				t.Errorf("Replies = %q, want all three parts of the reply", got)
+			}
+		})
+	}
+}
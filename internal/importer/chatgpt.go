@@ -0,0 +1,144 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"fpf/pkg/models"
+)
+
+const (
+	ChatGPTExportKind       = "chatgpt-export"
+	ChatGPTExportSourceName = "chatgpt"
+)
+
+func init() {
+	register(ChatGPTExportKind, ReadChatGPTExport)
+}
+
+type chatGPTConversation struct {
+	Title      string                 `json:"title"`
+	CreateTime float64                `json:"create_time"`
+	Mapping    map[string]chatGPTNode `json:"mapping"`
+}
+
+type chatGPTNode struct {
+	ID       string          `json:"id"`
+	Message  *chatGPTMessage `json:"message"`
+	Parent   *string         `json:"parent"`
+	Children []string        `json:"children"`
+}
+
+type chatGPTMessage struct {
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	CreateTime *float64 `json:"create_time"`
+	Content    struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+	} `json:"content"`
+	Metadata struct {
+		IsVisuallyHidden bool `json:"is_visually_hidden_from_conversation"`
+	} `json:"metadata"`
+}
+
+func ReadChatGPTExport(data []byte) (Result, error) {
+	var conversations []chatGPTConversation
+	if err := json.Unmarshal(data, &conversations); err != nil {
+		return Result{}, fmt.Errorf("failed to parse ChatGPT export: %w", err)
+	}
+
+	result := Result{Conversations: len(conversations)}
+	for _, conversation := range conversations {
+		project := projectLabel(ChatGPTExportSourceName, conversation.Title)
+
+		for _, message := range conversation.userMessages() {
+			text := strings.TrimSpace(message.text())
+			if text == "" {
+				continue
+			}
+
+			createTime := conversation.CreateTime
+			if message.CreateTime != nil {
+				createTime = *message.CreateTime
+			}
+
+			result.Prompts = append(result.Prompts, models.Prompt{
+				Display:   text,
+				Timestamp: unixSecondsToMillis(createTime),
+				Project:   project,
+				Source:    ChatGPTExportSourceName,
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// userMessages walks the conversation's node tree depth-first from its roots,
+// so messages come out in conversation order and edited branches are kept.
+func (c chatGPTConversation) userMessages() []*chatGPTMessage {
+	var roots []string
+	for id, node := range c.Mapping {
+		if node.Parent == nil || *node.Parent == "" {
+			roots = append(roots, id)
+		} else if _, ok := c.Mapping[*node.Parent]; !ok {
+			roots = append(roots, id)
+		}
+	}
+	sort.Strings(roots)
+
+	var messages []*chatGPTMessage
+	visited := make(map[string]bool, len(c.Mapping))
+	stack := make([]string, 0, len(roots))
+	for i := len(roots) - 1; i >= 0; i-- {
+		stack = append(stack, roots[i])
+	}
+
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+
+		node, ok := c.Mapping[id]
+		if !ok {
+			continue
+		}
+
+		if m := node.Message; m != nil && m.Author.Role == "user" && !m.Metadata.IsVisuallyHidden {
+			messages = append(messages, m)
+		}
+
+		for i := len(node.Children) - 1; i >= 0; i-- {
+			stack = append(stack, node.Children[i])
+		}
+	}
+
+	return messages
+}
+
+func (m *chatGPTMessage) text() string {
+	if m.Content.ContentType != "" && m.Content.ContentType != "text" && m.Content.ContentType != "multimodal_text" {
+		return ""
+	}
+
+	var textParts []string
+	for _, part := range m.Content.Parts {
+		var text string
+		if err := json.Unmarshal(part, &text); err == nil && text != "" {
+			textParts = append(textParts, text)
+		}
+	}
+	return strings.Join(textParts, "\n")
+}
+
+func unixSecondsToMillis(seconds float64) int64 {
+	return int64(math.Round(seconds * 1000))
+}
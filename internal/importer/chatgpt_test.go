@@ -0,0 +1,69 @@
+package importer
+
+import (
+	"reflect"
+	"testing"
+
+	"fpf/pkg/models"
+)
+
+const chatGPTExportFixture = `[
+  {
+    "title": "Postgres indexing",
+    "create_time": 1700000000.5,
+    "mapping": {
+      "root": {"id": "root", "message": null, "parent": null, "children": ["sys"]},
+      "sys": {
+        "id": "sys",
+        "message": {"author": {"role": "system"}, "create_time": null, "content": {"content_type": "text", "parts": [""]}, "metadata": {"is_visually_hidden_from_conversation": true}},
+        "parent": "root",
+        "children": ["u1"]
+      },
+      "u1": {
+        "id": "u1",
+        "message": {"author": {"role": "user"}, "create_time": 1700000010.25, "content": {"content_type": "text", "parts": ["When should I use a partial index?"]}, "metadata": {}},
+        "parent": "sys",
+        "children": ["a1"]
+      },
+      "a1": {
+        "id": "a1",
+        "message": {"author": {"role": "assistant"}, "create_time": 1700000020, "content": {"content_type": "text", "parts": ["When most rows are filtered out."]}, "metadata": {}},
+        "parent": "u1",
+        "children": ["u2", "u3"]
+      },
+      "u2": {
+        "id": "u2",
+        "message": {"author": {"role": "user"}, "create_time": 1700000030, "content": {"content_type": "multimodal_text", "parts": [{"content_type": "image_asset_pointer"}, "What about this plan?"]}, "metadata": {}},
+        "parent": "a1",
+        "children": []
+      },
+      "u3": {
+        "id": "u3",
+        "message": {"author": {"role": "user"}, "create_time": null, "content": {"content_type": "text", "parts": ["And a BRIN index?"]}, "metadata": {}},
+        "parent": "a1",
+        "children": []
+      }
+    }
+  }
+]`
+
+func TestReadChatGPTExport(t *testing.T) {
+	result, err := ReadChatGPTExport([]byte(chatGPTExportFixture))
+	if err != nil {
+		t.Fatalf("ReadChatGPTExport() error = %v", err)
+	}
+
+	project := "chatgpt/Postgres indexing"
+	want := []models.Prompt{
+		{Display: "When should I use a partial index?", Timestamp: 1700000010250, Project: project, Source: ChatGPTExportSourceName},
+		{Display: "What about this plan?", Timestamp: 1700000030000, Project: project, Source: ChatGPTExportSourceName},
+		{Display: "And a BRIN index?", Timestamp: 1700000000500, Project: project, Source: ChatGPTExportSourceName},
+	}
+
+	if result.Conversations != 1 {
+		t.Errorf("ReadChatGPTExport() conversations = %d, want 1", result.Conversations)
+	}
+	if !reflect.DeepEqual(result.Prompts, want) {
+		t.Errorf("ReadChatGPTExport() prompts = %v, want %v", result.Prompts, want)
+	}
+}
@@ -0,0 +1,89 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"fpf/pkg/models"
+)
+
+const (
+	ClaudeExportKind       = "claude-export"
+	ClaudeExportSourceName = "claude.ai"
+)
+
+func init() {
+	register(ClaudeExportKind, ReadClaudeExport)
+}
+
+type claudeConversation struct {
+	UUID         string          `json:"uuid"`
+	Name         string          `json:"name"`
+	CreatedAt    string          `json:"created_at"`
+	ChatMessages []claudeMessage `json:"chat_messages"`
+}
+
+type claudeMessage struct {
+	UUID      string          `json:"uuid"`
+	Text      string          `json:"text"`
+	Sender    string          `json:"sender"`
+	CreatedAt string          `json:"created_at"`
+	Content   []claudeContent `json:"content"`
+}
+
+type claudeContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func ReadClaudeExport(data []byte) (Result, error) {
+	var conversations []claudeConversation
+	if err := json.Unmarshal(data, &conversations); err != nil {
+		return Result{}, fmt.Errorf("failed to parse Claude export: %w", err)
+	}
+
+	result := Result{Conversations: len(conversations)}
+	for _, conversation := range conversations {
+		project := projectLabel(ClaudeExportSourceName, conversation.Name)
+
+		for _, message := range conversation.ChatMessages {
+			if message.Sender != "human" {
+				continue
+			}
+
+			text := strings.TrimSpace(message.text())
+			if text == "" {
+				continue
+			}
+
+			createdAt := message.CreatedAt
+			if createdAt == "" {
+				createdAt = conversation.CreatedAt
+			}
+
+			result.Prompts = append(result.Prompts, models.Prompt{
+				Display:   text,
+				Timestamp: parseTimestamp(createdAt),
+				Project:   project,
+				Source:    ClaudeExportSourceName,
+			})
+		}
+	}
+
+	return result, nil
+}
+
+func (m claudeMessage) text() string {
+	if m.Text != "" {
+		return m.Text
+	}
+
+	var textParts []string
+	for _, content := range m.Content {
+		if content.Type == "text" {
+			textParts = append(textParts, content.Text)
+		}
+	}
+	return strings.Join(textParts, "\n")
+}
@@ -0,0 +1,102 @@
+package importer
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"fpf/pkg/models"
+)
+
+const claudeExportFixture = `[
+  {
+    "uuid": "c1",
+    "name": "Rate limiting design",
+    "created_at": "2025-03-01T09:00:00.000000Z",
+    "chat_messages": [
+      {"uuid": "m1", "sender": "human", "text": "How should I rate limit the login endpoint?", "created_at": "2025-03-01T09:00:01.000000Z"},
+      {"uuid": "m2", "sender": "assistant", "text": "Use a token bucket.", "created_at": "2025-03-01T09:00:05.000000Z"},
+      {"uuid": "m3", "sender": "human", "text": "", "content": [{"type": "text", "text": "Show me it in Go"}], "created_at": "2025-03-01T09:01:00.000000Z"},
+      {"uuid": "m4", "sender": "human", "text": "   ", "created_at": "2025-03-01T09:02:00.000000Z"}
+    ]
+  },
+  {
+    "uuid": "c2",
+    "name": "",
+    "created_at": "2025-03-02T10:00:00.000000Z",
+    "chat_messages": [
+      {"uuid": "m5", "sender": "human", "text": "hello"}
+    ]
+  }
+]`
+
+func TestReadClaudeExport(t *testing.T) {
+	result, err := ReadClaudeExport([]byte(claudeExportFixture))
+	if err != nil {
+		t.Fatalf("ReadClaudeExport() error = %v", err)
+	}
+
+	want := []models.Prompt{
+		{
+			Display:   "How should I rate limit the login endpoint?",
+			Timestamp: time.Date(2025, 3, 1, 9, 0, 1, 0, time.UTC).UnixMilli(),
+			Project:   "claude.ai/Rate limiting design",
+			Source:    ClaudeExportSourceName,
+		},
+		{
+			Display:   "Show me it in Go",
+			Timestamp: time.Date(2025, 3, 1, 9, 1, 0, 0, time.UTC).UnixMilli(),
+			Project:   "claude.ai/Rate limiting design",
+			Source:    ClaudeExportSourceName,
+		},
+		{
+			Display:   "hello",
+			Timestamp: time.Date(2025, 3, 2, 10, 0, 0, 0, time.UTC).UnixMilli(),
+			Project:   "claude.ai/Untitled",
+			Source:    ClaudeExportSourceName,
+		},
+	}
+
+	if result.Conversations != 2 {
+		t.Errorf("ReadClaudeExport() conversations = %d, want 2", result.Conversations)
+	}
+	if !reflect.DeepEqual(result.Prompts, want) {
+		t.Errorf("ReadClaudeExport() prompts = %v, want %v", result.Prompts, want)
+	}
+}
+
+func TestImportZip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data-export.zip")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create zip: %v", err)
+	}
+
+	archive := zip.NewWriter(file)
+	w, err := archive.Create("data-2025-03-02/conversations.json")
+	if err != nil {
+		t.Fatalf("Failed to add zip entry: %v", err)
+	}
+	if _, err := w.Write([]byte(claudeExportFixture)); err != nil {
+		t.Fatalf("Failed to write zip entry: %v", err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("Failed to close zip: %v", err)
+	}
+	file.Close()
+
+	result, err := Import(ClaudeExportKind, path)
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if len(result.Prompts) != 3 {
+		t.Errorf("Import() returned %d prompts, want 3", len(result.Prompts))
+	}
+
+	if _, err := Import("unknown", path); err == nil {
+		t.Error("Import() with unknown kind returned nil error")
+	}
+}
@@ -0,0 +1,102 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"fpf/pkg/models"
+)
+
+const conversationsFileName = "conversations.json"
+
+type Result struct {
+	Prompts       []models.Prompt
+	Conversations int
+}
+
+type ReadFunc func(data []byte) (Result, error)
+
+var importers = map[string]ReadFunc{}
+
+func register(kind string, read ReadFunc) {
+	importers[kind] = read
+}
+
+func Kinds() []string {
+	kinds := make([]string, 0, len(importers))
+	for kind := range importers {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+func Import(kind, filePath string) (Result, error) {
+	read, ok := importers[kind]
+	if !ok {
+		return Result{}, fmt.Errorf("unknown import kind %q (available: %s)", kind, strings.Join(Kinds(), ", "))
+	}
+
+	data, err := readConversations(filePath)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return read(data)
+}
+
+// readConversations returns the contents of conversations.json, either read
+// directly or extracted from a data export zip.
+func readConversations(filePath string) ([]byte, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.EqualFold(path.Ext(filePath), ".zip") {
+		return data, nil
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", filePath, err)
+	}
+
+	for _, file := range archive.File {
+		if path.Base(file.Name) != conversationsFileName {
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+
+	return nil, fmt.Errorf("%s not found in %s", conversationsFileName, filePath)
+}
+
+func parseTimestamp(timestamp string) int64 {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+func projectLabel(prefix, title string) string {
+	title = strings.Join(strings.Fields(title), " ")
+	if title == "" {
+		title = "Untitled"
+	}
+	return prefix + "/" + title
+}
@@ -0,0 +1,324 @@
+package index
+
+import (
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"fpf/pkg/models"
+)
+
+const (
+	FileName = "index.gob"
+
+	indexVersion = 2
+	gramSize     = 3
+	// compactThreshold is how many texts that are no longer in the history
+	// the index keeps before it is rebuilt from scratch.
+	compactThreshold = 1024
+)
+
+// Index maps the characters and trigrams of prompt texts to the IDs of the
+// texts that contain them, so a search only has to score the prompts that
+// could match. Texts are only ever added: a prompt that disappears from the
+// history keeps its ID until the index is compacted.
+//
+// Characters are folded the way the fuzzy scorer folds case, and trigrams are
+// taken from the lower-cased text the literal terms are matched against. The
+// characters of the lower-cased text are indexed too, as lower-casing can map
+// a character outside its case folds, e.g. "İ" to "i".
+type Index struct {
+	Version int
+	Docs    map[uint64]uint32
+	Chars   map[rune][]uint32
+	Grams   map[uint64][]uint32
+
+	path  string
+	dirty bool
+	mu    sync.RWMutex
+}
+
+func New(path string) *Index {
+	return &Index{
+		Version: indexVersion,
+		Docs:    make(map[uint64]uint32),
+		Chars:   make(map[rune][]uint32),
+		Grams:   make(map[uint64][]uint32),
+		path:    path,
+	}
+}
+
+// Load reads the index at path, starting a new one when there is none or it
+// was written by another version of fpf.
+func Load(path string) (*Index, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return New(path), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	ix := New(path)
+	if err := gob.NewDecoder(file).Decode(ix); err != nil || ix.Version != indexVersion {
+		return New(path), nil
+	}
+	return ix, nil
+}
+
+// Save writes the index if texts were added since it was loaded. An index
+// without a path is kept in memory only.
+func (ix *Index) Save() error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	if !ix.dirty || ix.path == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(ix.path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(ix.path), filepath.Base(ix.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(ix); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), ix.path); err != nil {
+		return err
+	}
+
+	ix.dirty = false
+	return nil
+}
+
+// Bind indexes any prompts the index hasn't seen yet and returns a Snapshot
+// for looking up positions in prompts.
+func (ix *Index) Bind(prompts []models.Prompt) *Snapshot {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	keys := make([]uint64, len(prompts))
+	live := make(map[uint64]bool, len(prompts))
+	for i := range prompts {
+		keys[i] = textKey(prompts[i].Display)
+		live[keys[i]] = true
+	}
+
+	if stale := len(ix.Docs) - len(live); stale > compactThreshold && stale > len(live) {
+		ix.Docs = make(map[uint64]uint32)
+		ix.Chars = make(map[rune][]uint32)
+		ix.Grams = make(map[uint64][]uint32)
+		ix.dirty = true
+	}
+
+	ids := make([]uint32, len(prompts))
+	for i, key := range keys {
+		id, ok := ix.Docs[key]
+		if !ok {
+			id = ix.add(key, prompts[i].Display)
+		}
+		ids[i] = id
+	}
+
+	return &Snapshot{index: ix, chars: ix.Chars, grams: ix.Grams, ids: ids, size: len(ix.Docs)}
+}
+
+// add gives text the next ID and appends it to the postings of each of its
+// characters and trigrams, which keeps every posting list sorted.
+func (ix *Index) add(key uint64, text string) uint32 {
+	id := uint32(len(ix.Docs))
+	ix.Docs[key] = id
+	ix.dirty = true
+
+	lower := strings.ToLower(text)
+	for _, r := range uniqueChars(text + lower) {
+		ix.Chars[r] = append(ix.Chars[r], id)
+	}
+	for _, gram := range uniqueGrams(lower) {
+		ix.Grams[gram] = append(ix.Grams[gram], id)
+	}
+	return id
+}
+
+// Snapshot is an Index bound to one slice of prompts. Lookups return IDs,
+// which Positions turns into indexes into that slice. It keeps the postings
+// it was bound with, as compacting the index renumbers every text.
+type Snapshot struct {
+	index *Index
+	chars map[rune][]uint32
+	grams map[uint64][]uint32
+	ids   []uint32
+	size  int
+}
+
+// WithChars returns the IDs of texts containing every character of text,
+// ignoring case.
+func (s *Snapshot) WithChars(text string) []uint32 {
+	s.index.mu.RLock()
+	defer s.index.mu.RUnlock()
+
+	var lists [][]uint32
+	for _, r := range uniqueChars(text) {
+		lists = append(lists, s.chars[r])
+	}
+	return intersectAll(lists)
+}
+
+// WithSubstring returns the IDs of texts whose lower-cased form may contain
+// lower, which must already be lower case. Texts shorter than a trigram fall
+// back to WithChars.
+func (s *Snapshot) WithSubstring(lower string) []uint32 {
+	grams := uniqueGrams(lower)
+	if len(grams) == 0 {
+		return s.WithChars(lower)
+	}
+
+	s.index.mu.RLock()
+	defer s.index.mu.RUnlock()
+
+	lists := make([][]uint32, len(grams))
+	for i, gram := range grams {
+		lists[i] = s.grams[gram]
+	}
+	return intersectAll(lists)
+}
+
+// Positions returns the ascending indexes of the bound prompts whose text has
+// one of ids.
+func (s *Snapshot) Positions(ids []uint32) []int {
+	wanted := make([]bool, s.size)
+	for _, id := range ids {
+		if int(id) < s.size {
+			wanted[id] = true
+		}
+	}
+
+	var positions []int
+	for i, id := range s.ids {
+		if wanted[id] {
+			positions = append(positions, i)
+		}
+	}
+	return positions
+}
+
+// Intersect returns the IDs in both a and b, which must be sorted.
+func Intersect(a, b []uint32) []uint32 {
+	result := make([]uint32, 0, min(len(a), len(b)))
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	return result
+}
+
+// Union returns the IDs in either a or b, which must be sorted.
+func Union(a, b []uint32) []uint32 {
+	result := make([]uint32, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			result = append(result, a[i])
+			i++
+		case a[i] > b[j]:
+			result = append(result, b[j])
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	result = append(result, a[i:]...)
+	return append(result, b[j:]...)
+}
+
+// intersectAll intersects the shortest lists first, so the result shrinks as
+// quickly as possible. It always returns a new slice.
+func intersectAll(lists [][]uint32) []uint32 {
+	if len(lists) == 0 {
+		return nil
+	}
+	slices.SortFunc(lists, func(a, b []uint32) int { return len(a) - len(b) })
+
+	result := slices.Clone(lists[0])
+	for _, list := range lists[1:] {
+		if len(result) == 0 {
+			break
+		}
+		result = Intersect(result, list)
+	}
+	return result
+}
+
+// foldChar maps every rune that folds to the same character, e.g. "k", "K"
+// and the Kelvin sign, to the smallest of them.
+func foldChar(r rune) rune {
+	smallest := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		smallest = min(smallest, f)
+	}
+	return smallest
+}
+
+func uniqueChars(text string) []rune {
+	var chars []rune
+	for _, r := range text {
+		chars = append(chars, foldChar(r))
+	}
+	slices.Sort(chars)
+	return slices.Compact(chars)
+}
+
+func uniqueGrams(lower string) []uint64 {
+	runes := []rune(lower)
+	if len(runes) < gramSize {
+		return nil
+	}
+
+	grams := make([]uint64, 0, len(runes)-gramSize+1)
+	for i := 0; i+gramSize <= len(runes); i++ {
+		grams = append(grams, uint64(runes[i])<<42|uint64(runes[i+1])<<21|uint64(runes[i+2]))
+	}
+	slices.Sort(grams)
+	return slices.Compact(grams)
+}
+
+// textKey is the 64-bit FNV-1a hash of text.
+func textKey(text string) uint64 {
+	const (
+		offset = 14695981039346656037
+		prime  = 1099511628211
+	)
+	hash := uint64(offset)
+	for i := 0; i < len(text); i++ {
+		hash ^= uint64(text[i])
+		hash *= prime
+	}
+	return hash
+}
@@ -0,0 +1,140 @@
+package index
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"fpf/pkg/models"
+)
+
+func promptsOf(texts ...string) []models.Prompt {
+	prompts := make([]models.Prompt, len(texts))
+	for i, text := range texts {
+		prompts[i] = models.Prompt{Display: text}
+	}
+	return prompts
+}
+
+func TestSnapshotLookups(t *testing.T) {
+	prompts := promptsOf(
+		"Fix the login bug",
+		"add tests for the KELVIN scale",
+		"fix the bug in login",
+		"Straße",
+		"fix the login bug",
+	)
+	snapshot := New("").Bind(prompts)
+
+	tests := []struct {
+		name     string
+		lookup   func(string) []uint32
+		text     string
+		expected []int
+	}{
+		{"chars", snapshot.WithChars, "fxlg", []int{0, 2, 4}},
+		{"chars fold case", snapshot.WithChars, "KELV", []int{1}},
+		{"chars fold kelvin sign", snapshot.WithChars, "K", []int{1}},
+		{"chars missing", snapshot.WithChars, "zq", nil},
+		{"substring", snapshot.WithSubstring, "login bug", []int{0, 4}},
+		{"substring lower case only", snapshot.WithSubstring, "straße", []int{3}},
+		{"substring short", snapshot.WithSubstring, "ix", []int{0, 2, 4}},
+		{"substring missing", snapshot.WithSubstring, "bug in the", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := snapshot.Positions(tt.lookup(tt.text)); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Positions(%q) = %v, want %v", tt.text, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBindIncremental(t *testing.T) {
+	ix := New("")
+	first := ix.Bind(promptsOf("deploy staging", "run tests"))
+
+	next := promptsOf("fix the parser", "deploy staging", "run tests")
+	snapshot := ix.Bind(next)
+
+	if len(ix.Docs) != 3 {
+		t.Fatalf("index has %d texts, want 3", len(ix.Docs))
+	}
+	if got := snapshot.Positions(snapshot.WithSubstring("parser")); !reflect.DeepEqual(got, []int{0}) {
+		t.Errorf("Positions(parser) = %v, want [0]", got)
+	}
+	if got := snapshot.Positions(snapshot.WithSubstring("deploy")); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("Positions(deploy) = %v, want [1]", got)
+	}
+	if got := first.Positions(first.WithSubstring("deploy")); !reflect.DeepEqual(got, []int{0}) {
+		t.Errorf("earlier snapshot Positions(deploy) = %v, want [0]", got)
+	}
+	if got := first.Positions(first.WithSubstring("parser")); got != nil {
+		t.Errorf("earlier snapshot Positions(parser) = %v, want nil", got)
+	}
+}
+
+func TestBindCompacts(t *testing.T) {
+	ix := New("")
+
+	old := make([]string, 2*compactThreshold)
+	for i := range old {
+		old[i] = fmt.Sprintf("old prompt %d", i)
+	}
+	before := ix.Bind(promptsOf(old...))
+
+	snapshot := ix.Bind(promptsOf("new prompt"))
+	if len(ix.Docs) != 1 {
+		t.Errorf("index has %d texts after compacting, want 1", len(ix.Docs))
+	}
+	if got := snapshot.Positions(snapshot.WithSubstring("prompt")); !reflect.DeepEqual(got, []int{0}) {
+		t.Errorf("Positions(prompt) = %v, want [0]", got)
+	}
+	if got := before.Positions(before.WithSubstring("prompt 1999")); !reflect.DeepEqual(got, []int{1999}) {
+		t.Errorf("earlier snapshot Positions(prompt 1999) = %v, want [1999]", got)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", FileName)
+	prompts := promptsOf("deploy staging", "run tests")
+
+	ix, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() on missing file error = %v", err)
+	}
+	ix.Bind(prompts)
+	if err := ix.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Docs, ix.Docs) || !reflect.DeepEqual(loaded.Grams, ix.Grams) || !reflect.DeepEqual(loaded.Chars, ix.Chars) {
+		t.Errorf("Load() returned a different index than was saved")
+	}
+
+	loaded.Bind(prompts)
+	if loaded.dirty {
+		t.Errorf("Bind() of already indexed prompts marked the index as changed")
+	}
+}
+
+func TestIntersectUnion(t *testing.T) {
+	a := []uint32{1, 3, 5, 7}
+	b := []uint32{2, 3, 7, 8}
+
+	if got := Intersect(a, b); !reflect.DeepEqual(got, []uint32{3, 7}) {
+		t.Errorf("Intersect() = %v, want [3 7]", got)
+	}
+	if got := Union(a, b); !reflect.DeepEqual(got, []uint32{1, 2, 3, 5, 7, 8}) {
+		t.Errorf("Union() = %v, want [1 2 3 5 7 8]", got)
+	}
+	if got := Union(nil, b); !reflect.DeepEqual(got, b) {
+		t.Errorf("Union(nil, b) = %v, want %v", got, b)
+	}
+}
@@ -0,0 +1,188 @@
+package matcher
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"fpf/internal/index"
+	"fpf/pkg/models"
+)
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+	// bm25Scale turns BM25's fractional scores into the integer scores every
+	// Matcher returns.
+	bm25Scale = 1000
+)
+
+// BM25 matches terms word by word: every word of a term has to start a word
+// of the text. Matches are scored with Okapi BM25 over every prompt, so rare
+// words count for more than common ones, and a word in a short prompt counts
+// for more than the same word lost in a long pasted log.
+type BM25 struct {
+	docs   int
+	avgLen float64
+	// vocab is every word in the prompts, sorted, and docsBefore[i] the
+	// number of prompts containing each word before vocab[i], so the prompts
+	// containing words with a given prefix can be counted in two searches.
+	vocab      []string
+	docsBefore []int
+}
+
+func NewBM25(prompts []models.Prompt) *BM25 {
+	var (
+		docFreq = make(map[string]int)
+		total   int
+	)
+	for i := range prompts {
+		words := tokenize(strings.ToLower(prompts[i].Display))
+		total += len(words)
+		slices.Sort(words)
+		for _, word := range slices.Compact(words) {
+			docFreq[word]++
+		}
+	}
+
+	b := &BM25{docs: len(prompts), avgLen: 1}
+	if len(prompts) > 0 && total > 0 {
+		b.avgLen = float64(total) / float64(len(prompts))
+	}
+
+	b.vocab = make([]string, 0, len(docFreq))
+	for word := range docFreq {
+		b.vocab = append(b.vocab, word)
+	}
+	slices.Sort(b.vocab)
+
+	b.docsBefore = make([]int, len(b.vocab)+1)
+	for i, word := range b.vocab {
+		b.docsBefore[i+1] = b.docsBefore[i] + docFreq[word]
+	}
+	return b
+}
+
+func (b *BM25) Name() string {
+	return StrategyBM25.String()
+}
+
+func (b *BM25) Match(text, term string) (int, []int, bool) {
+	words := tokenize(term)
+	if len(words) == 0 {
+		return 0, nil, true
+	}
+
+	prefixes := make([][]rune, len(words))
+	for i, word := range words {
+		prefixes[i] = []rune(word)
+	}
+
+	var (
+		freq      = make([]int, len(words))
+		positions []int
+		length    int
+		token     []rune
+		start     int
+	)
+	endToken := func() {
+		if len(token) == 0 {
+			return
+		}
+		length++
+		for i, prefix := range prefixes {
+			if hasRunePrefix(token, prefix) {
+				freq[i]++
+				for j := range prefix {
+					positions = append(positions, start+j)
+				}
+			}
+		}
+		token = token[:0]
+	}
+
+	i := 0
+	for _, r := range text {
+		if isWordRune(r) {
+			if len(token) == 0 {
+				start = i
+			}
+			token = append(token, unicode.ToLower(r))
+		} else {
+			endToken()
+		}
+		i++
+	}
+	endToken()
+
+	score := 0.0
+	norm := bm25K1 * (1 - bm25B + bm25B*float64(length)/b.avgLen)
+	for i, word := range words {
+		if freq[i] == 0 {
+			return 0, nil, false
+		}
+		tf := float64(freq[i])
+		score += b.idf(word) * tf * (bm25K1 + 1) / (tf + norm)
+	}
+	return int(math.Round(score * bm25Scale)), positions, true
+}
+
+// idf counts every prompt containing a word that starts with prefix. A
+// prompt with several such words is counted for each, so the count is capped
+// at the number of prompts.
+func (b *BM25) idf(prefix string) float64 {
+	lo := sort.SearchStrings(b.vocab, prefix)
+	hi := lo + sort.Search(len(b.vocab)-lo, func(i int) bool {
+		return !strings.HasPrefix(b.vocab[lo+i], prefix)
+	})
+	df := float64(min(b.docsBefore[hi]-b.docsBefore[lo], b.docs))
+	n := float64(b.docs)
+	return math.Log(1 + (n-df+0.5)/(df+0.5))
+}
+
+// Implies holds when every word of prev starts a word of term. A literal term
+// can occur in the middle of a word, so it implies nothing.
+func (b *BM25) Implies(term string, literal bool, prev string) bool {
+	prevWords := tokenize(prev)
+	if len(prevWords) == 0 {
+		return true
+	}
+	if literal {
+		return false
+	}
+
+	words := tokenize(term)
+	for _, p := range prevWords {
+		if !slices.ContainsFunc(words, func(w string) bool { return strings.HasPrefix(w, p) }) {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *BM25) Candidates(snapshot *index.Snapshot, term string) ([]uint32, bool) {
+	words := tokenize(term)
+	if len(words) == 0 {
+		return nil, false
+	}
+
+	ids := snapshot.WithSubstring(words[0])
+	for _, word := range words[1:] {
+		ids = index.Intersect(ids, snapshot.WithSubstring(word))
+	}
+	return ids, true
+}
+
+func tokenize(lower string) []string {
+	return strings.FieldsFunc(lower, func(r rune) bool { return !isWordRune(r) })
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func hasRunePrefix(s, prefix []rune) bool {
+	return len(s) >= len(prefix) && slices.Equal(s[:len(prefix)], prefix)
+}
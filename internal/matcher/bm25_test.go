@@ -0,0 +1,137 @@
+package matcher
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"fpf/pkg/models"
+)
+
+func TestBM25Match(t *testing.T) {
+	b := NewBM25([]models.Prompt{
+		{Display: "deploy staging"},
+		{Display: "independent deploys"},
+		{Display: "run tests"},
+	})
+
+	tests := []struct {
+		text      string
+		term      string
+		ok        bool
+		positions []int
+	}{
+		{"deploy staging", "dep", true, []int{0, 1, 2}},
+		{"independent work", "dep", false, nil},
+		{"Deploy the DEPLOYS", "dep", true, []int{0, 1, 2, 11, 12, 13}},
+		{"update readme.md", "readme.md", true, []int{7, 8, 9, 10, 11, 12, 14, 15}},
+		{"update readme", "readme.md", false, nil},
+		{"anything", "--", true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text+"/"+tt.term, func(t *testing.T) {
+			_, positions, ok := b.Match(tt.text, tt.term)
+			if ok != tt.ok || !reflect.DeepEqual(positions, tt.positions) {
+				t.Errorf("Match(%q, %q) = %v, %v, want %v, %v", tt.text, tt.term, positions, ok, tt.positions, tt.ok)
+			}
+		})
+	}
+}
+
+func TestBM25Ranking(t *testing.T) {
+	log := "error: connection reset by peer\n" + strings.Repeat("    at handler.go:42 in process request\n", 100)
+	prompts := []models.Prompt{
+		{Display: log},
+		{Display: "why does the connection drop"},
+		{Display: "retry the flaky test"},
+		{Display: "fix the flaky test in ci"},
+		{Display: "the test is flaky again"},
+	}
+
+	q, err := ParseQuery("conn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches := q.WithMatcher(NewBM25(prompts)).Match(prompts)
+	if len(matches) != 2 || matches[0].Prompt.Display != prompts[1].Display {
+		t.Errorf("short prompt should outrank a long log with the same word, got %v", displays(matches))
+	}
+
+	// Fuzzy matching finds "ftt" scattered through prompts, BM25 wants a word.
+	q, err = ParseQuery("ftt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fuzzy, bm25 := q.Match(prompts), q.WithMatcher(NewBM25(prompts)).Match(prompts); len(fuzzy) == 0 || len(bm25) != 0 {
+		t.Errorf("ftt matched %d prompts fuzzily and %d with BM25, want some and none", len(fuzzy), len(bm25))
+	}
+
+	q, err = ParseQuery("retry test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches = q.WithMatcher(NewBM25(prompts)).Match(prompts)
+	if len(matches) != 1 || matches[0].Prompt.Display != "retry the flaky test" {
+		t.Errorf("every word has to match, got %v", displays(matches))
+	}
+
+	b := NewBM25(prompts)
+	retry, _, _ := b.Match("retry the flaky test", "retry")
+	test, _, _ := b.Match("retry the flaky test", "test")
+	if retry <= test {
+		t.Errorf("rare word scored %d, want more than the common word's %d", retry, test)
+	}
+}
+
+func displays(matches []Match) []string {
+	result := make([]string, len(matches))
+	for i, match := range matches {
+		result[i] = match.Prompt.Display
+	}
+	return result
+}
+
+func TestBM25Implies(t *testing.T) {
+	b := NewBM25(nil)
+
+	tests := []struct {
+		term     string
+		literal  bool
+		prev     string
+		expected bool
+	}{
+		{"deploy", false, "dep", true},
+		{"deploy staging", false, "stag", true},
+		{"dep", false, "deploy", false},
+		{"deploy", true, "dep", false},
+		{"deploy", true, "-", true},
+		{"readme.md", false, "read", true},
+		{"dpl", false, "dep", false},
+	}
+
+	for _, tt := range tests {
+		if got := b.Implies(tt.term, tt.literal, tt.prev); got != tt.expected {
+			t.Errorf("Implies(%q, %v, %q) = %v, want %v", tt.term, tt.literal, tt.prev, got, tt.expected)
+		}
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	for _, name := range []string{"fuzzy", "bm25", "BM25"} {
+		strategy, err := ParseStrategy(name)
+		if err != nil {
+			t.Fatalf("ParseStrategy(%q) error = %v", name, err)
+		}
+		if !strings.EqualFold(strategy.String(), name) {
+			t.Errorf("ParseStrategy(%q) = %v", name, strategy)
+		}
+	}
+
+	if _, err := ParseStrategy("regex"); err == nil {
+		t.Error("ParseStrategy(regex) should fail")
+	}
+	if got := StrategyBM25.Next(); got != StrategyFuzzy {
+		t.Errorf("StrategyBM25.Next() = %v, want fuzzy", got)
+	}
+}
@@ -0,0 +1,282 @@
+package matcher
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"fpf/pkg/models"
+	"github.com/sahilm/fuzzy"
+)
+
+const (
+	operatorPrefix = "%"
+	escapePrefix   = "\\"
+	negatePrefix   = "!"
+	rangeSeparator = ".."
+	dateLayout     = "2006-01-02"
+)
+
+// Filter is a single %operator term of a query, e.g. "%p website" or
+// "%!b main". A prompt passes the filter when it matches the value, or when it
+// doesn't and the filter is negated.
+type Filter struct {
+	Operator string
+	Value    string
+	Negate   bool
+
+	match func(models.Prompt) bool
+}
+
+func (f Filter) Match(p models.Prompt) bool {
+	return f.match(p) != f.Negate
+}
+
+func (f Filter) String() string {
+	prefix := operatorPrefix
+	if f.Negate {
+		prefix += negatePrefix
+	}
+	return prefix + f.Operator + " " + f.Value
+}
+
+type operator struct {
+	// phrase operators take every word up to the next operator as their
+	// value, rather than a single word.
+	phrase  bool
+	compile func(value string, now time.Time) (func(models.Prompt) bool, error)
+}
+
+var (
+	operators = map[string]operator{
+		"p":   {compile: compileProject},
+		"src": {compile: compileSource},
+		"r":   {phrase: true, compile: compileReply},
+		"f":   {compile: compileFile},
+		"t":   {compile: compileTime},
+		"b":   {compile: compileBranch},
+		"s":   {compile: compileSession},
+		"len": {compile: compileLength},
+	}
+
+	relativeTimePattern = regexp.MustCompile(`^(\d+)(h|d|w|mo|y)$`)
+	comparisonPattern   = regexp.MustCompile(`^(>=|<=|>|<|=)?(\d+)$`)
+)
+
+// parseOperator reports whether field is a known operator token such as "%p"
+// or "%!src", returning its name and whether it is negated. Anything else,
+// like the "%s" of a printf verb, is text.
+func parseOperator(field string) (name string, negate bool, ok bool) {
+	rest, ok := strings.CutPrefix(field, operatorPrefix)
+	if !ok {
+		return "", false, false
+	}
+
+	rest, negate = strings.CutPrefix(rest, negatePrefix)
+	if _, known := operators[rest]; !known {
+		return "", false, false
+	}
+	return rest, negate, true
+}
+
+// unescapeField drops the backslash of a field written as "\%s", which
+// searches for "%s" even though it is an operator.
+func unescapeField(field string) string {
+	if rest, ok := strings.CutPrefix(field, escapePrefix); ok && strings.HasPrefix(rest, operatorPrefix) {
+		return rest
+	}
+	return field
+}
+
+func compileProject(value string, _ time.Time) (func(models.Prompt) bool, error) {
+	query := strings.ToLower(value)
+
+	// Histories have far fewer projects than prompts, so remember the answer
+	// for each one. Matching runs in parallel shards, hence the sync.Map.
+	var matched sync.Map
+	return func(p models.Prompt) bool {
+		if ok, seen := matched.Load(p.Project); seen {
+			return ok.(bool)
+		}
+		ok := len(fuzzy.Find(query, []string{strings.ToLower(p.ProjectPath())})) > 0
+		matched.Store(p.Project, ok)
+		return ok
+	}, nil
+}
+
+// compileSource matches the whole source name, as some names extend others,
+// e.g. claude and claude.ai.
+func compileSource(value string, _ time.Time) (func(models.Prompt) bool, error) {
+	return func(p models.Prompt) bool {
+		return strings.EqualFold(p.Source, value)
+	}, nil
+}
+
+func compileReply(value string, _ time.Time) (func(models.Prompt) bool, error) {
+	pattern := parsePattern(value)
+	return func(p models.Prompt) bool {
+		_, _, ok := pattern.match(p.ReplyText(), Fuzzy{})
+		return ok
+	}, nil
+}
+
+func compileFile(value string, _ time.Time) (func(models.Prompt) bool, error) {
+	query := strings.ToLower(value)
+	return func(p models.Prompt) bool {
+		for _, file := range p.TouchedFiles() {
+			if strings.Contains(strings.ToLower(file), query) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func compileBranch(value string, _ time.Time) (func(models.Prompt) bool, error) {
+	query := strings.ToLower(value)
+	return func(p models.Prompt) bool {
+		return p.GitBranch != "" && strings.Contains(strings.ToLower(p.GitBranch), query)
+	}, nil
+}
+
+func compileSession(value string, _ time.Time) (func(models.Prompt) bool, error) {
+	query := strings.ToLower(value)
+	return func(p models.Prompt) bool {
+		return p.SessionID != "" && strings.HasPrefix(strings.ToLower(p.SessionID), query)
+	}, nil
+}
+
+// compileTime accepts a relative window ("3d", "12h", "2w", "6mo", "1y"), a
+// single day ("2025-06-01"), a comparison (">2025-06-01", "<2025-06-01") or an
+// inclusive range of days ("2025-06-01..2025-06-15", either end optional).
+func compileTime(value string, now time.Time) (func(models.Prompt) bool, error) {
+	from, to, err := parseTimeWindow(value, now)
+	if err != nil {
+		return nil, fmt.Errorf("%%t: invalid time %q (want 3d, >2025-06-01 or 2025-06-01..2025-06-15)", value)
+	}
+	return func(p models.Prompt) bool {
+		return p.Timestamp >= from && p.Timestamp < to
+	}, nil
+}
+
+func parseTimeWindow(value string, now time.Time) (int64, int64, error) {
+	if matches := relativeTimePattern.FindStringSubmatch(value); matches != nil {
+		n, err := strconv.Atoi(matches[1])
+		if err != nil {
+			return 0, 0, err
+		}
+
+		var since time.Time
+		switch matches[2] {
+		case "h":
+			since = now.Add(-time.Duration(n) * time.Hour)
+		case "d":
+			since = now.AddDate(0, 0, -n)
+		case "w":
+			since = now.AddDate(0, 0, -7*n)
+		case "mo":
+			since = now.AddDate(0, -n, 0)
+		case "y":
+			since = now.AddDate(-n, 0, 0)
+		}
+		return since.UnixMilli(), math.MaxInt64, nil
+	}
+
+	if rest, ok := strings.CutPrefix(value, ">"); ok {
+		day, err := parseDay(rest)
+		return day.AddDate(0, 0, 1).UnixMilli(), math.MaxInt64, err
+	}
+	if rest, ok := strings.CutPrefix(value, "<"); ok {
+		day, err := parseDay(rest)
+		return math.MinInt64, day.UnixMilli(), err
+	}
+
+	start, end, isRange := strings.Cut(value, rangeSeparator)
+	if !isRange {
+		end = start
+	}
+	if start == "" && end == "" {
+		return 0, 0, fmt.Errorf("empty time range")
+	}
+
+	from, to := int64(math.MinInt64), int64(math.MaxInt64)
+	if start != "" {
+		day, err := parseDay(start)
+		if err != nil {
+			return 0, 0, err
+		}
+		from = day.UnixMilli()
+	}
+	if end != "" {
+		day, err := parseDay(end)
+		if err != nil {
+			return 0, 0, err
+		}
+		to = day.AddDate(0, 0, 1).UnixMilli()
+	}
+	if from >= to {
+		return 0, 0, fmt.Errorf("time range ends before it starts")
+	}
+	return from, to, nil
+}
+
+func parseDay(value string) (time.Time, error) {
+	return time.ParseInLocation(dateLayout, value, time.Local)
+}
+
+// compileLength compares the prompt's length in characters: "500" for an
+// exact length, ">500", ">=500", "<100", "<=100", or an inclusive "100..500".
+func compileLength(value string, _ time.Time) (func(models.Prompt) bool, error) {
+	lo, hi, err := parseLengthRange(value)
+	if err != nil {
+		return nil, fmt.Errorf("%%len: invalid length %q (want >500, <100 or 100..500)", value)
+	}
+	return func(p models.Prompt) bool {
+		n := utf8.RuneCountInString(p.Display)
+		return n >= lo && n <= hi
+	}, nil
+}
+
+func parseLengthRange(value string) (int, int, error) {
+	if start, end, ok := strings.Cut(value, rangeSeparator); ok {
+		lo, err := strconv.Atoi(start)
+		if err != nil {
+			return 0, 0, err
+		}
+		hi, err := strconv.Atoi(end)
+		if err != nil {
+			return 0, 0, err
+		}
+		if lo > hi {
+			return 0, 0, fmt.Errorf("length range ends before it starts")
+		}
+		return lo, hi, nil
+	}
+
+	matches := comparisonPattern.FindStringSubmatch(value)
+	if matches == nil {
+		return 0, 0, fmt.Errorf("not a length")
+	}
+	n, err := strconv.Atoi(matches[2])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	switch matches[1] {
+	case ">":
+		return n + 1, math.MaxInt, nil
+	case ">=":
+		return n, math.MaxInt, nil
+	case "<":
+		return 0, n - 1, nil
+	case "<=":
+		return 0, n, nil
+	default:
+		return n, n, nil
+	}
+}
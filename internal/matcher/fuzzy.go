@@ -0,0 +1,57 @@
+package matcher
+
+import (
+	"unicode/utf8"
+
+	"fpf/internal/index"
+	"github.com/sahilm/fuzzy"
+)
+
+// Fuzzy matches terms as case-insensitive subsequences, scored by
+// sahilm/fuzzy.
+type Fuzzy struct{}
+
+func (Fuzzy) Name() string {
+	return StrategyFuzzy.String()
+}
+
+func (Fuzzy) Match(text, term string) (int, []int, bool) {
+	if !mayContainFold(text, term) {
+		return 0, nil, false
+	}
+	matches := fuzzy.FindNoSort(term, []string{text})
+	if len(matches) == 0 {
+		return 0, nil, false
+	}
+	return matches[0].Score, runeIndexes(text, matches[0].MatchedIndexes), true
+}
+
+// Implies holds for literal terms too, as text containing term also contains
+// every subsequence of it.
+func (Fuzzy) Implies(term string, _ bool, prev string) bool {
+	return isSubsequence(prev, term)
+}
+
+func (Fuzzy) Candidates(snapshot *index.Snapshot, term string) ([]uint32, bool) {
+	return snapshot.WithChars(term), true
+}
+
+// mayContainFold is a cheap, allocation-free check that lower-case ASCII sub
+// is a case-insensitive subsequence of s, so most fuzzy misses skip the
+// scorer. It always reports true when sub isn't ASCII.
+func mayContainFold(s, sub string) bool {
+	j := 0
+	for i := 0; i < len(s) && j < len(sub); i++ {
+		if sub[j] >= utf8.RuneSelf {
+			return true
+		}
+		c := s[i]
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		if c == sub[j] {
+			j++
+		}
+	}
+	return j == len(sub)
+}
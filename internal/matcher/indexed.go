@@ -0,0 +1,78 @@
+package matcher
+
+import (
+	"context"
+
+	"fpf/internal/index"
+	"fpf/pkg/models"
+)
+
+// MatchIndexed is MatchContext, but first uses snapshot, which must be bound
+// to prompts, to skip the prompts that can't match. The result is the same
+// as MatchContext's.
+func (q Query) MatchIndexed(ctx context.Context, prompts []models.Prompt, snapshot *index.Snapshot) ([]Match, error) {
+	if snapshot == nil {
+		return q.MatchContext(ctx, prompts)
+	}
+
+	ids, ok := q.pattern.candidates(snapshot, q.strategy())
+	if !ok {
+		return q.MatchContext(ctx, prompts)
+	}
+
+	positions := snapshot.Positions(ids)
+	candidates := make([]Match, len(positions))
+	for i, position := range positions {
+		candidates[i] = Match{Prompt: &prompts[position], Index: position}
+	}
+	return q.matchCandidates(ctx, candidates)
+}
+
+// candidates returns the IDs of the texts that could match every group, or
+// false when no group can be narrowed down with the index.
+func (p pattern) candidates(snapshot *index.Snapshot, m Matcher) ([]uint32, bool) {
+	var (
+		ids    []uint32
+		pruned bool
+	)
+	for _, group := range p {
+		groupIDs, ok := groupCandidates(group, snapshot, m)
+		if !ok {
+			continue
+		}
+		if pruned {
+			ids = index.Intersect(ids, groupIDs)
+		} else {
+			ids, pruned = groupIDs, true
+		}
+	}
+	return ids, pruned
+}
+
+// groupCandidates returns the texts that could match any of group's terms.
+// m narrows down plain terms and a literal match needs every trigram, while an
+// inverse term can match almost anything, so a group with one can't be
+// narrowed down.
+func groupCandidates(group []term, snapshot *index.Snapshot, m Matcher) ([]uint32, bool) {
+	var ids []uint32
+	for _, t := range group {
+		if t.inverse {
+			return nil, false
+		}
+
+		var (
+			termIDs []uint32
+			ok      = true
+		)
+		if t.kind == termFuzzy {
+			termIDs, ok = m.Candidates(snapshot, t.text)
+		} else {
+			termIDs = snapshot.WithSubstring(t.text)
+		}
+		if !ok {
+			return nil, false
+		}
+		ids = index.Union(ids, termIDs)
+	}
+	return ids, true
+}
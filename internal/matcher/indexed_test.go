@@ -0,0 +1,116 @@
+package matcher
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"fpf/internal/index"
+	"fpf/pkg/models"
+)
+
+var indexedPrompts = []models.Prompt{
+	{Display: "Update README.md with the KELVIN scale"},
+	{Display: "convert 300 K to celsius"},
+	{Display: "Straße names in the ſession store"},
+	{Display: "İstanbul timezone handling"},
+	{Display: "fix 🐛 in the parser"},
+	{Display: "ab"},
+	{Display: "İS"},
+	{Display: "fix the bug in authentication module"},
+	{Display: "fix the bug in authentication module"},
+	{Display: "Σίσυφος ΣΊΣΥΦΟΣ"},
+}
+
+// TestMatchIndexedMatchesScan checks that pruning with the index never
+// changes the result of a search.
+func TestMatchIndexedMatchesScan(t *testing.T) {
+	prompts := append(generatePrompts(5000), indexedPrompts...)
+
+	queries := []string{
+		"", "a", "ab", "auth", "auth tok", "AUTH", "fix bug", "xyz", "k", "kelvin",
+		"'kelvin", "'K", "'k", "^fix", "^fix the", "module$", "^ab$", "'se", "'ses",
+		"ſ", "s", "'ſession", "session", "straße", "STRASSE", "istanbul", "'i̇stan",
+		"🐛", "'🐛 parser", "σίσυφος", "'σίσυφος", "ς", "go | rust", "'auth | ^fix",
+		"auth | !cache", "!cache", "!cache auth", "'session !cache", "%p api retry",
+		"a | b | c", "readme.md$", "'ea", "^", "'", "$", "'is", "is", "readme.md",
+		"fix-the", "-", "300 k", "'ıs",
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	modifiers := []string{"", "'", "^", "!", "'", ""}
+	for i := 0; i < 200; i++ {
+		var terms []string
+		for j := 0; j < 1+rng.Intn(3); j++ {
+			word := benchWords[rng.Intn(len(benchWords))]
+			word = word[rng.Intn(len(word)):]
+			word = word[:1+rng.Intn(len(word))]
+			terms = append(terms, modifiers[rng.Intn(len(modifiers))]+word)
+			if rng.Intn(5) == 0 {
+				terms = append(terms, "|")
+			}
+		}
+		queries = append(queries, strings.Join(terms, " "))
+	}
+
+	ix := index.New("")
+	snapshot := ix.Bind(prompts)
+
+	for _, strategy := range []Strategy{StrategyFuzzy, StrategyBM25} {
+		m := strategy.NewMatcher(prompts)
+		for _, query := range queries {
+			t.Run(strategy.String()+"/"+query, func(t *testing.T) {
+				q, err := ParseQuery(query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				q = q.WithMatcher(m)
+
+				want := q.Match(prompts)
+				got, err := q.MatchIndexed(context.Background(), prompts, snapshot)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("MatchIndexed(%q) returned %d matches, want the %d of a full scan", query, len(got), len(want))
+				}
+			})
+		}
+	}
+}
+
+// TestMatchIndexedIncremental checks the index against a scan as new prompts
+// are added to it, including through snapshots bound before the additions.
+func TestMatchIndexedIncremental(t *testing.T) {
+	all := generatePrompts(4000)
+	ix := index.New("")
+
+	var snapshots []*index.Snapshot
+	var batches [][]models.Prompt
+	for end := 1000; end <= len(all); end += 1000 {
+		prompts := append([]models.Prompt(nil), all[:end]...)
+		snapshots = append(snapshots, ix.Bind(prompts))
+		batches = append(batches, prompts)
+	}
+
+	for _, query := range []string{"auth tok", "'session", "^fix the", "retry | parser"} {
+		q, err := ParseQuery(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, prompts := range batches {
+			t.Run(fmt.Sprintf("%s/%d", query, len(prompts)), func(t *testing.T) {
+				got, err := q.MatchIndexed(context.Background(), prompts, snapshots[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := q.Match(prompts); !reflect.DeepEqual(got, want) {
+					t.Errorf("MatchIndexed(%q) returned %d matches, want %d", query, len(got), len(want))
+				}
+			})
+		}
+	}
+}
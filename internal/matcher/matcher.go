@@ -1,72 +1,228 @@
 package matcher
 
 import (
-	"regexp"
+	"context"
+	"runtime"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"fpf/pkg/models"
-	"github.com/sahilm/fuzzy"
 )
 
+// Query is a parsed search: free text matched against the prompt with the
+// extended search syntax, narrowed by every filter.
 type Query struct {
-	PromptQuery  string
-	ProjectQuery string
+	PromptQuery string
+	Filters     []Filter
+
+	pattern pattern
+	matcher Matcher
 }
 
-var projectPattern = regexp.MustCompile(`%p\s+(\S+)`)
+const (
+	// minShardSize keeps small histories on a single goroutine, where
+	// starting more would cost more than it saves.
+	minShardSize        = 4096
+	cancelCheckInterval = 1024
+)
+
+// Match is a prompt that satisfied a query, with its position in the matched
+// slice, its fuzzy score and the rune positions in its Display that the query
+// text matched.
+type Match struct {
+	Prompt    *models.Prompt
+	Index     int
+	Score     int
+	Positions []int
+}
 
-func ParseQuery(query string) Query {
-	q := Query{}
+// ParseQuery splits query into its %operator filters and the remaining text.
+// Unknown operators and operators without a value are left as text, as is an
+// operator escaped as "\%s". It returns an error for malformed time or length
+// values.
+func ParseQuery(query string) (Query, error) {
+	var (
+		q    Query
+		text []string
+		now  = time.Now()
+	)
+
+	fields := strings.Fields(query)
+	for i := 0; i < len(fields); i++ {
+		name, negate, ok := parseOperator(fields[i])
+		if !ok {
+			text = append(text, unescapeField(fields[i]))
+			continue
+		}
 
-	if matches := projectPattern.FindStringSubmatch(query); len(matches) > 1 {
-		q.ProjectQuery = matches[1]
-		q.PromptQuery = strings.Join(strings.Fields(projectPattern.ReplaceAllString(query, "")), " ")
-	} else {
-		q.PromptQuery = query
+		op := operators[name]
+		var value []string
+		for i+1 < len(fields) {
+			if _, _, next := parseOperator(fields[i+1]); next {
+				break
+			}
+			i++
+			value = append(value, unescapeField(fields[i]))
+			if !op.phrase {
+				break
+			}
+		}
+		if len(value) == 0 {
+			text = append(text, fields[i])
+			continue
+		}
+
+		filter := Filter{Operator: name, Value: strings.Join(value, " "), Negate: negate}
+		match, err := op.compile(filter.Value, now)
+		if err != nil {
+			return Query{}, err
+		}
+		filter.match = match
+		q.Filters = append(q.Filters, filter)
 	}
 
-	return q
+	q.PromptQuery = strings.Join(text, " ")
+	q.pattern = parsePattern(q.PromptQuery)
+	return q, nil
 }
 
-func MatchPrompts(prompts []models.Prompt, query string) []models.Prompt {
-	if query == "" {
-		return prompts
+func (q Query) Match(prompts []models.Prompt) []Match {
+	matches, _ := q.MatchContext(context.Background(), prompts)
+	return matches
+}
+
+// MatchContext matches prompts in parallel shards, returning ctx's error if it
+// is cancelled before matching finishes.
+func (q Query) MatchContext(ctx context.Context, prompts []models.Prompt) ([]Match, error) {
+	candidates := make([]Match, len(prompts))
+	for i := range prompts {
+		candidates[i] = Match{Prompt: &prompts[i], Index: i}
 	}
+	return q.matchCandidates(ctx, candidates)
+}
+
+// Refine matches q against the results of an earlier query that q Narrows,
+// giving the same result as matching every prompt again.
+func (q Query) Refine(ctx context.Context, previous []Match) ([]Match, error) {
+	candidates := slices.Clone(previous)
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Index < candidates[j].Index
+	})
+	return q.matchCandidates(ctx, candidates)
+}
 
-	parsedQuery := ParseQuery(query)
+// Narrows reports whether every prompt matching q also matches prev, so q can
+// Refine prev's results. Both must use the same Matcher, filters can only be
+// added, and every term of prev must be implied by a term of q, e.g. "auth" by
+// "autho" or "'auth".
+func (q Query) Narrows(prev Query) bool {
+	if q.strategy().Name() != prev.strategy().Name() {
+		return false
+	}
+	for _, filter := range prev.Filters {
+		if !slices.ContainsFunc(q.Filters, func(f Filter) bool { return f.String() == filter.String() }) {
+			return false
+		}
+	}
+	return q.pattern.implies(prev.pattern, q.strategy())
+}
 
-	filtered := prompts
-	if parsedQuery.ProjectQuery != "" {
-		filtered = make([]models.Prompt, 0, len(prompts))
-		for _, p := range prompts {
-			if matchesProject(p, parsedQuery.ProjectQuery) {
-				filtered = append(filtered, p)
+// matchCandidates keeps the candidates that match q, in index order, and then
+// sorts them by score when q has text to score against.
+func (q Query) matchCandidates(ctx context.Context, candidates []Match) ([]Match, error) {
+	shards := min(runtime.NumCPU(), max(len(candidates)/minShardSize, 1))
+	size := (len(candidates) + shards - 1) / max(shards, 1)
+	results := make([][]Match, shards)
+
+	var wg sync.WaitGroup
+	for shard := 0; shard < shards; shard++ {
+		part := candidates[min(shard*size, len(candidates)):min((shard+1)*size, len(candidates))]
+		wg.Add(1)
+		go func(shard int, part []Match) {
+			defer wg.Done()
+			results[shard] = q.matchShard(ctx, part)
+		}(shard, part)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	matches := slices.Concat(results...)
+	if len(q.pattern) > 0 {
+		sort.SliceStable(matches, func(i, j int) bool {
+			return matches[i].Score > matches[j].Score
+		})
+	}
+	return matches, nil
+}
+
+func (q Query) matchShard(ctx context.Context, part []Match) []Match {
+	m := q.strategy()
+	matches := make([]Match, 0, len(part))
+	for i, candidate := range part {
+		if i%cancelCheckInterval == 0 && ctx.Err() != nil {
+			return nil
+		}
+		if !q.matchesFilters(*candidate.Prompt) {
+			continue
+		}
+
+		candidate.Score, candidate.Positions = 0, nil
+		if len(q.pattern) > 0 {
+			score, positions, ok := q.pattern.match(candidate.Prompt.Display, m)
+			if !ok {
+				continue
 			}
+			candidate.Score, candidate.Positions = score, positions
 		}
+		matches = append(matches, candidate)
 	}
+	return matches
+}
+
+// Highlights returns the rune positions of every occurrence of the query
+// text's terms in text.
+func (q Query) Highlights(text string) []int {
+	return q.pattern.highlights(text, q.strategy())
+}
 
-	if parsedQuery.PromptQuery == "" {
-		return filtered
+func (q Query) matchesFilters(p models.Prompt) bool {
+	for _, filter := range q.Filters {
+		if !filter.Match(p) {
+			return false
+		}
 	}
+	return true
+}
 
-	texts := make([]string, len(filtered))
-	for i, p := range filtered {
-		texts[i] = p.Display
+func MatchPrompts(prompts []models.Prompt, query string) ([]models.Prompt, error) {
+	if query == "" {
+		return prompts, nil
 	}
 
-	matches := fuzzy.Find(parsedQuery.PromptQuery, texts)
+	matches, err := FindMatches(prompts, query)
+	if err != nil {
+		return nil, err
+	}
 
 	result := make([]models.Prompt, len(matches))
 	for i, match := range matches {
-		result[i] = filtered[match.Index]
+		result[i] = *match.Prompt
 	}
-
-	return result
+	return result, nil
 }
 
-func matchesProject(prompt models.Prompt, projectQuery string) bool {
-	query := strings.ToLower(projectQuery)
-	projectPath := strings.ToLower(prompt.ProjectPath())
-
-	return len(fuzzy.Find(query, []string{projectPath})) > 0
+// FindMatches is MatchPrompts with the matched positions kept, for
+// highlighting.
+func FindMatches(prompts []models.Prompt, query string) ([]Match, error) {
+	q, err := ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return q.Match(prompts), nil
 }
@@ -1,10 +1,62 @@
 package matcher
 
 import (
-	"fpf/pkg/models"
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"fpf/internal/history"
+	"fpf/internal/index"
+	"fpf/pkg/models"
+)
+
+var (
+	benchWords = []string{
+		"fix", "the", "bug", "in", "authentication", "module", "add", "tests", "for",
+		"login", "handler", "refactor", "database", "code", "update", "documentation",
+		"deploy", "staging", "rate", "limiting", "session", "token", "refresh", "cache",
+		"parser", "websocket", "retry", "timeout", "config", "migrate", "schema", "api",
+	}
+	benchProjects = []string{"/home/user/website", "/home/user/api", "/home/user/cli", "/home/user/docs"}
+
+	generatedPrompts   = map[int][]models.Prompt{}
+	generatedPromptsMu sync.Mutex
 )
 
+// generatePrompts returns n deterministic prompts of 4 to 15 words, shared
+// between benchmarks of the same size.
+func generatePrompts(n int) []models.Prompt {
+	generatedPromptsMu.Lock()
+	defer generatedPromptsMu.Unlock()
+
+	if prompts, ok := generatedPrompts[n]; ok {
+		return prompts
+	}
+
+	rng := rand.New(rand.NewSource(int64(n)))
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	prompts := make([]models.Prompt, n)
+	for i := range prompts {
+		words := make([]string, 4+rng.Intn(12))
+		for j := range words {
+			words[j] = benchWords[rng.Intn(len(benchWords))]
+		}
+		prompts[i] = models.Prompt{
+			Display:   strings.Join(words, " "),
+			Project:   benchProjects[rng.Intn(len(benchProjects))],
+			Timestamp: start + int64(i)*60_000,
+			Uses:      1 + rng.Intn(5),
+		}
+	}
+
+	generatedPrompts[n] = prompts
+	return prompts
+}
+
 func BenchmarkMatchPrompts(b *testing.B) {
 	prompts := make([]models.Prompt, 100)
 	for i := 0; i < 100; i++ {
@@ -17,7 +69,7 @@ func BenchmarkMatchPrompts(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_ = MatchPrompts(prompts, "bug auth")
+		_, _ = MatchPrompts(prompts, "bug auth")
 	}
 }
 
@@ -25,6 +77,113 @@ func BenchmarkParseQuery(b *testing.B) {
 	query := "implement feature %p myapp something else"
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_ = ParseQuery(query)
+		_, _ = ParseQuery(query)
+	}
+}
+
+func BenchmarkMatchLarge(b *testing.B) {
+	queries := []string{"auth tok", "'session !cache", "%p api retry"}
+
+	for _, n := range []int{100_000, 1_000_000} {
+		prompts := generatePrompts(n)
+		for _, query := range queries {
+			q, err := ParseQuery(query)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.Run(fmt.Sprintf("%d/%s", n, query), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := q.MatchContext(context.Background(), prompts); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkMatchIndexedLarge(b *testing.B) {
+	queries := []string{"auth tok", "'session !cache", "%p api retry"}
+
+	for _, n := range []int{100_000, 1_000_000} {
+		prompts := generatePrompts(n)
+		snapshot := index.New("").Bind(prompts)
+		for _, query := range queries {
+			q, err := ParseQuery(query)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.Run(fmt.Sprintf("%d/%s", n, query), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := q.MatchIndexed(context.Background(), prompts, snapshot); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkRefineLarge(b *testing.B) {
+	for _, n := range []int{100_000, 1_000_000} {
+		prompts := generatePrompts(n)
+		prev, _ := ParseQuery("auth")
+		next, _ := ParseQuery("authtok")
+		previous := prev.Match(prompts)
+
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := next.Refine(context.Background(), previous); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRankLarge(b *testing.B) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, n := range []int{100_000, 1_000_000} {
+		q, _ := ParseQuery("auth")
+		matches := q.Match(generatePrompts(n))
+		ranked := make([]Match, len(matches))
+
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				copy(ranked, matches)
+				Rank(ranked, SortRelevance, DefaultWeights, nil, now)
+			}
+		})
+
+		// Loaded prompts have their hashes worked out, so boosts don't hash
+		// every match.
+		hashed := make([]models.Prompt, n)
+		for i, p := range generatePrompts(n) {
+			hashed[i] = p.Hashed()
+		}
+		matches = q.Match(hashed)
+		boosts := Boosts{matches[0].Prompt.Hash(): 1}
+
+		b.Run(fmt.Sprintf("%d/boosted", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				copy(ranked, matches)
+				Rank(ranked, SortRelevance, DefaultWeights, boosts, now)
+			}
+		})
+	}
+}
+
+func BenchmarkGroupLarge(b *testing.B) {
+	for _, n := range []int{100_000} {
+		prompts := generatePrompts(n)
+
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				history.NewGrouper(history.DedupGlobal).Group(prompts)
+			}
+		})
 	}
 }
@@ -1,46 +1,146 @@
 package matcher
 
 import (
-	"fpf/pkg/models"
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
 	"testing"
+	"time"
+
+	"fpf/pkg/models"
 )
 
 func TestParseQuery(t *testing.T) {
 	tests := []struct {
 		input           string
 		expectedPrompt  string
-		expectedProject string
+		expectedFilters []string
 	}{
 		{
-			input:           "fix bug",
-			expectedPrompt:  "fix bug",
-			expectedProject: "",
+			input:          "fix bug",
+			expectedPrompt: "fix bug",
 		},
 		{
 			input:           "fix bug %p website",
 			expectedPrompt:  "fix bug",
-			expectedProject: "website",
+			expectedFilters: []string{"%p website"},
 		},
 		{
 			input:           "%p regdelete",
 			expectedPrompt:  "",
-			expectedProject: "regdelete",
+			expectedFilters: []string{"%p regdelete"},
 		},
 		{
 			input:           "implement feature %p myapp something else",
 			expectedPrompt:  "implement feature something else",
-			expectedProject: "myapp",
+			expectedFilters: []string{"%p myapp"},
+		},
+		{
+			input:           "fix bug %src codex",
+			expectedPrompt:  "fix bug",
+			expectedFilters: []string{"%src codex"},
+		},
+		{
+			input:           "%src claude deploy %p website",
+			expectedPrompt:  "deploy",
+			expectedFilters: []string{"%src claude", "%p website"},
+		},
+		{
+			input:           "%r rate limiter",
+			expectedFilters: []string{"%r rate limiter"},
+		},
+		{
+			input:           "login %r added a rate limiter %p api",
+			expectedPrompt:  "login",
+			expectedFilters: []string{"%r added a rate limiter", "%p api"},
+		},
+		{
+			input:           "%f internal/auth/session.go refresh",
+			expectedPrompt:  "refresh",
+			expectedFilters: []string{"%f internal/auth/session.go"},
+		},
+		{
+			input:           "deploy %t 3d %b main %s 8f2c %len >500",
+			expectedPrompt:  "deploy",
+			expectedFilters: []string{"%t 3d", "%b main", "%s 8f2c", "%len >500"},
+		},
+		{
+			input:           "%!p website %!src codex tests",
+			expectedPrompt:  "tests",
+			expectedFilters: []string{"%!p website", "%!src codex"},
+		},
+		{
+			input:          "50% faster %20",
+			expectedPrompt: "50% faster %20",
+		},
+		{
+			input:          "Sprintf %d %v in logs %!",
+			expectedPrompt: "Sprintf %d %v in logs %!",
+		},
+		{
+			input:          "printf %s",
+			expectedPrompt: "printf %s",
+		},
+		{
+			input:           "%!b %p website",
+			expectedPrompt:  "%!b",
+			expectedFilters: []string{"%p website"},
+		},
+		{
+			input:          `printf \%s verb`,
+			expectedPrompt: "printf %s verb",
+		},
+		{
+			input:           `%r use \%w to wrap`,
+			expectedFilters: []string{"%r use %w to wrap"},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.input, func(t *testing.T) {
-			result := ParseQuery(tt.input)
+			result, err := ParseQuery(tt.input)
+			if err != nil {
+				t.Fatalf("ParseQuery(%q) error = %v", tt.input, err)
+			}
 			if result.PromptQuery != tt.expectedPrompt {
 				t.Errorf("ParseQuery(%q).PromptQuery = %q, want %q", tt.input, result.PromptQuery, tt.expectedPrompt)
 			}
-			if result.ProjectQuery != tt.expectedProject {
-				t.Errorf("ParseQuery(%q).ProjectQuery = %q, want %q", tt.input, result.ProjectQuery, tt.expectedProject)
+
+			var filters []string
+			for _, f := range result.Filters {
+				filters = append(filters, f.String())
+			}
+			if !slices.Equal(filters, tt.expectedFilters) {
+				t.Errorf("ParseQuery(%q).Filters = %q, want %q", tt.input, filters, tt.expectedFilters)
+			}
+		})
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"%t yesterday", `%t: invalid time "yesterday"`},
+		{"%t 2025-06-15..2025-06-01", `%t: invalid time "2025-06-15..2025-06-01"`},
+		{"%t >june", `%t: invalid time ">june"`},
+		{"%len long", `%len: invalid length "long"`},
+		{"%len 500..100", `%len: invalid length "500..100"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := ParseQuery(tt.input)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.expected) {
+				t.Errorf("ParseQuery(%q) error = %v, want %q", tt.input, err, tt.expected)
+			}
+			if _, err := MatchPrompts(nil, tt.input); err == nil {
+				t.Errorf("MatchPrompts(%q) error = nil, want an error", tt.input)
 			}
 		})
 	}
@@ -48,10 +148,13 @@ func TestParseQuery(t *testing.T) {
 
 func TestMatchPrompts(t *testing.T) {
 	prompts := []models.Prompt{
-		{Display: "fix the bug in authentication", Project: "/home/user/website"},
-		{Display: "add new feature to dashboard", Project: "/home/user/webapp"},
-		{Display: "refactor database code", Project: "/home/user/website"},
-		{Display: "update documentation", Project: "/home/user/docs"},
+		{Display: "fix the bug in authentication", Project: "/home/user/website", Source: "claude", Replies: []string{"The session token was never refreshed."}, Tools: []models.ToolCall{
+			{Name: "Read", FilePath: "/home/user/website/internal/auth/token.go"},
+			{Name: "Edit", FilePath: "/home/user/website/internal/auth/Session.go"},
+		}},
+		{Display: "add new feature to dashboard", Project: "/home/user/webapp", Source: "codex", Replies: []string{"Added a usage chart to the dashboard."}},
+		{Display: "refactor database code", Project: "/home/user/website", Source: "codex"},
+		{Display: "update documentation", Project: "/home/user/docs", Source: "claude"},
 	}
 
 	tests := []struct {
@@ -84,14 +187,472 @@ func TestMatchPrompts(t *testing.T) {
 			expectedCount: 1,
 			description:   "prompt and project filter",
 		},
+		{
+			query:         "%src codex",
+			expectedCount: 2,
+			description:   "source filter only",
+		},
+		{
+			query:         "%src claude %p website",
+			expectedCount: 1,
+			description:   "source and project filter",
+		},
+		{
+			query:         "%r session token",
+			expectedCount: 1,
+			description:   "reply filter only",
+		},
+		{
+			query:         "dash %r usage chart",
+			expectedCount: 1,
+			description:   "prompt and reply filter",
+		},
+		{
+			query:         "fix %r usage chart",
+			expectedCount: 0,
+			description:   "prompt and reply must both match",
+		},
+		{
+			query:         "%f auth/session.go",
+			expectedCount: 1,
+			description:   "file filter is a case-insensitive path fragment",
+		},
+		{
+			query:         "%f auth/session.go dashboard",
+			expectedCount: 0,
+			description:   "prompt and file filter",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.description, func(t *testing.T) {
-			result := MatchPrompts(prompts, tt.query)
+			result, err := MatchPrompts(prompts, tt.query)
+			if err != nil {
+				t.Fatalf("MatchPrompts(%q) error = %v", tt.query, err)
+			}
 			if len(result) != tt.expectedCount {
 				t.Errorf("MatchPrompts with query %q returned %d results, want %d", tt.query, len(result), tt.expectedCount)
 			}
 		})
 	}
 }
+
+func TestParsePattern(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected pattern
+	}{
+		{
+			input:    "",
+			expected: nil,
+		},
+		{
+			input: "auth test",
+			expected: pattern{
+				{{kind: termFuzzy, text: "auth"}},
+				{{kind: termFuzzy, text: "test"}},
+			},
+		},
+		{
+			input: "'Exact ^prefix suffix$ ^equal$",
+			expected: pattern{
+				{{kind: termExact, text: "exact"}},
+				{{kind: termPrefix, text: "prefix"}},
+				{{kind: termSuffix, text: "suffix"}},
+				{{kind: termEqual, text: "equal"}},
+			},
+		},
+		{
+			input: "!wip !^draft !.md$",
+			expected: pattern{
+				{{kind: termExact, text: "wip", inverse: true}},
+				{{kind: termPrefix, text: "draft", inverse: true}},
+				{{kind: termSuffix, text: ".md", inverse: true}},
+			},
+		},
+		{
+			input: "go | rust test",
+			expected: pattern{
+				{{kind: termFuzzy, text: "go"}, {kind: termFuzzy, text: "rust"}},
+				{{kind: termFuzzy, text: "test"}},
+			},
+		},
+		{
+			input: "| go | ' ! ^ $",
+			expected: pattern{
+				{{kind: termFuzzy, text: "go"}, {kind: termFuzzy, text: "$"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := parsePattern(tt.input); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parsePattern(%q) = %+v, want %+v", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMatchPromptsExtendedSyntax(t *testing.T) {
+	prompts := []models.Prompt{
+		{Display: "write a test for the auth handler"},
+		{Display: "fix the auth redirect"},
+		{Display: "test the billing webhook"},
+		{Display: "update README.md"},
+		{Display: "authenticate requests in the go client"},
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{
+			query:    "auth test",
+			expected: []string{"write a test for the auth handler", "authenticate requests in the go client"},
+		},
+		{
+			query:    "'auth handler",
+			expected: []string{"write a test for the auth handler"},
+		},
+		{
+			query:    "^test",
+			expected: []string{"test the billing webhook"},
+		},
+		{
+			query:    "redirect$",
+			expected: []string{"fix the auth redirect"},
+		},
+		{
+			query:    "^update readme.md$",
+			expected: []string{"update README.md"},
+		},
+		{
+			query:    "^readme.md$",
+			expected: nil,
+		},
+		{
+			query:    "^fix | readme.md$",
+			expected: []string{"fix the auth redirect", "update README.md"},
+		},
+		{
+			query:    "'auth !test",
+			expected: []string{"fix the auth redirect", "authenticate requests in the go client"},
+		},
+		{
+			query:    "billing | redirect",
+			expected: []string{"fix the auth redirect", "test the billing webhook"},
+		},
+		{
+			query:    "!auth !README",
+			expected: []string{"test the billing webhook"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			var got []string
+			result, err := MatchPrompts(prompts, tt.query)
+			if err != nil {
+				t.Fatalf("MatchPrompts(%q) error = %v", tt.query, err)
+			}
+			for _, p := range result {
+				got = append(got, p.Display)
+			}
+			slices.Sort(got)
+			want := slices.Clone(tt.expected)
+			slices.Sort(want)
+			if !slices.Equal(got, want) {
+				t.Errorf("MatchPrompts(%q) = %q, want %q", tt.query, got, want)
+			}
+		})
+	}
+}
+
+func TestMatchPromptsExtendedRanking(t *testing.T) {
+	prompts := []models.Prompt{
+		{Display: "a long prompt that eventually mentions the auth module and its tests"},
+		{Display: "auth tests"},
+	}
+
+	got, err := MatchPrompts(prompts, "'auth test")
+	if err != nil {
+		t.Fatalf("MatchPrompts() error = %v", err)
+	}
+	if len(got) != 2 || got[0].Display != "auth tests" {
+		t.Errorf("MatchPrompts() ranked %v, want the tighter match first", got)
+	}
+}
+
+func TestMatchPromptsOperators(t *testing.T) {
+	now := time.Now()
+	day := func(value string) int64 {
+		d, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d.Add(12 * time.Hour).UnixMilli()
+	}
+
+	prompts := []models.Prompt{
+		{Display: "recent", Timestamp: now.Add(-time.Hour).UnixMilli(), GitBranch: "main", SessionID: "8f2c1a", Source: "claude"},
+		{Display: "last week", Timestamp: now.AddDate(0, 0, -6).UnixMilli(), GitBranch: "feature/login", SessionID: "19bd07", Source: "claude.ai"},
+		{Display: "early june", Timestamp: day("2025-06-01"), Project: "/home/user/website"},
+		{Display: "mid june", Timestamp: day("2025-06-15"), Project: "/home/user/api"},
+		{Display: strings.Repeat("long ", 120), Timestamp: day("2025-07-01")},
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"%t 1d", []string{"recent"}},
+		{"%t 2w", []string{"recent", "last week"}},
+		{"%!t 2w %len <100", []string{"early june", "mid june"}},
+		{"%t 2025-06-01", []string{"early june"}},
+		{"%t >2025-06-01 %t <2025-07-01", []string{"mid june"}},
+		{"%t 2025-06-01..2025-06-15", []string{"early june", "mid june"}},
+		{"%t ..2025-06-14", []string{"early june"}},
+		{"%b main", []string{"recent"}},
+		{"%b login", []string{"last week"}},
+		{"%!b main", []string{"last week", "early june", "mid june", strings.Repeat("long ", 120)}},
+		{"%s 19B", []string{"last week"}},
+		{"%len >500", []string{strings.Repeat("long ", 120)}},
+		{"%len 6..9", []string{"recent", "last week", "mid june"}},
+		{"%!p website %t 2025-06-01..", []string{"recent", "last week", "mid june", strings.Repeat("long ", 120)}},
+		{"%src claude", []string{"recent"}},
+		{"%src Claude.ai", []string{"last week"}},
+		{"%src cl", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			result, err := MatchPrompts(prompts, tt.query)
+			if err != nil {
+				t.Fatalf("MatchPrompts(%q) error = %v", tt.query, err)
+			}
+			var got []string
+			for _, p := range result {
+				got = append(got, p.Display)
+			}
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("MatchPrompts(%q) = %q, want %q", tt.query, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFindMatchesPositions(t *testing.T) {
+	tests := []struct {
+		display  string
+		query    string
+		expected []int
+	}{
+		{"fix the bug", "fxb", []int{0, 2, 8}},
+		{"fix the bug", "'BUG", []int{8, 9, 10}},
+		{"fix the bug", "^fix bug$", []int{0, 1, 2, 8, 9, 10}},
+		{"fix the bug", "'the !wip", []int{4, 5, 6}},
+		{"fix the bug", "%p website", nil},
+		{"ünïcode bug", "bug", []int{8, 9, 10}},
+		{"ünïcode bug", "'ïco", []int{2, 3, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			prompts := []models.Prompt{{Display: tt.display, Project: "/home/user/website"}}
+			matches, err := FindMatches(prompts, tt.query)
+			if err != nil {
+				t.Fatalf("FindMatches(%q) error = %v", tt.query, err)
+			}
+			if len(matches) != 1 {
+				t.Fatalf("FindMatches(%q) returned %d matches, want 1", tt.query, len(matches))
+			}
+			if !slices.Equal(matches[0].Positions, tt.expected) {
+				t.Errorf("FindMatches(%q) positions = %v, want %v", tt.query, matches[0].Positions, tt.expected)
+			}
+		})
+	}
+}
+
+func TestQueryHighlights(t *testing.T) {
+	tests := []struct {
+		query    string
+		text     string
+		expected []int
+	}{
+		{"auth", "auth, then Auth again", []int{0, 1, 2, 3, 11, 12, 13, 14}},
+		{"'test !auth", "test the tests", []int{0, 1, 2, 3, 9, 10, 11, 12}},
+		{"tst", "a test", []int{2, 4, 5}},
+		{"^fix", "fix a fix", []int{0, 1, 2}},
+		{"", "anything", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("ParseQuery(%q) error = %v", tt.query, err)
+			}
+			if got := q.Highlights(tt.text); !slices.Equal(got, tt.expected) {
+				t.Errorf("Highlights(%q) = %v, want %v", tt.text, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestQueryNarrows(t *testing.T) {
+	tests := []struct {
+		prev     string
+		next     string
+		expected bool
+	}{
+		{"", "anything", true},
+		{"auth", "auth", true},
+		{"auth", "autho", true},
+		{"auth", "a_u_t_h", true},
+		{"auth", "auth test", true},
+		{"auth", "'auth", true},
+		{"'aut", "'auth", true},
+		{"'aut", "auth", false},
+		{"^fix", "^fixes", true},
+		{"^fix", "^fix$", true},
+		{"^fix", "fix", false},
+		{"go$", "ngo$", true},
+		{"!wip", "!wip", true},
+		{"!wip", "!wipe", false},
+		{"go", "go |", true},
+		{"go", "go | rust", false},
+		{"go | rust", "go", true},
+		{"auth", "auth %p api", true},
+		{"%p api auth", "auth", false},
+		{"%p ap", "%p api", false},
+		{"%len >5", "%len >50", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prev+" -> "+tt.next, func(t *testing.T) {
+			prev, err := ParseQuery(tt.prev)
+			if err != nil {
+				t.Fatal(err)
+			}
+			next, err := ParseQuery(tt.next)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := next.Narrows(prev); got != tt.expected {
+				t.Errorf("ParseQuery(%q).Narrows(%q) = %v, want %v", tt.next, tt.prev, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestQueryNarrowsStrategies(t *testing.T) {
+	bm25 := NewBM25(nil)
+	parse := func(query string, m Matcher) Query {
+		q, err := ParseQuery(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return q.WithMatcher(m)
+	}
+
+	tests := []struct {
+		prev     Query
+		next     Query
+		expected bool
+	}{
+		{parse("dep", bm25), parse("deploy", bm25), true},
+		{parse("dep", bm25), parse("dep staging", bm25), true},
+		{parse("dep", bm25), parse("dpl", bm25), false},
+		{parse("dep", bm25), parse("'deploy", bm25), false},
+		{parse("dep", nil), parse("deploy", bm25), false},
+		{parse("dep", bm25), parse("deploy", Fuzzy{}), false},
+		{parse("dep", nil), parse("deploy", Fuzzy{}), true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.next.Narrows(tt.prev); got != tt.expected {
+			t.Errorf("%s %q.Narrows(%s %q) = %v, want %v", tt.next.strategy().Name(), tt.next.PromptQuery, tt.prev.strategy().Name(), tt.prev.PromptQuery, got, tt.expected)
+		}
+	}
+}
+
+func TestRefineMatchesFullSearch(t *testing.T) {
+	// Large enough to be matched in several shards.
+	prompts := generatePrompts(3 * minShardSize * runtime.NumCPU())
+
+	sequences := [][]string{
+		{"a", "au", "aut", "auth", "auth t", "auth to", "auth tok"},
+		{"'s", "'se", "'ses", "'session", "'session !", "'session !c", "'session !cache"},
+		{"r", "re", "ret", "retr", "retry %p api"},
+		{"^f", "^fi", "^fix", "^fix bug$"},
+	}
+
+	for _, sequence := range sequences {
+		t.Run(sequence[len(sequence)-1], func(t *testing.T) {
+			var (
+				prev     Query
+				previous = Query{}.Match(prompts)
+			)
+			for _, query := range sequence {
+				q, err := ParseQuery(query)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				want := q.Match(prompts)
+				if !q.Narrows(prev) {
+					previous, prev = want, q
+					continue
+				}
+
+				got, err := q.Refine(context.Background(), previous)
+				if err != nil {
+					t.Fatalf("Refine(%q) error = %v", query, err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("Refine(%q) returned %d matches differing from a full search of %d", query, len(got), len(want))
+				}
+				previous, prev = got, q
+			}
+		})
+	}
+}
+
+func TestMatchContextSharding(t *testing.T) {
+	prompts := generatePrompts(2 * minShardSize * runtime.NumCPU())
+	q, err := ParseQuery("auth tok %p api")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := q.Match(prompts)
+
+	var want []Match
+	for i := range prompts {
+		if !q.matchesFilters(prompts[i]) {
+			continue
+		}
+		if score, positions, ok := q.pattern.match(prompts[i].Display, Fuzzy{}); ok {
+			want = append(want, Match{Prompt: &prompts[i], Index: i, Score: score, Positions: positions})
+		}
+	}
+	sort.SliceStable(want, func(i, j int) bool { return want[i].Score > want[j].Score })
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Match() returned %d matches differing from %d sequential matches", len(got), len(want))
+	}
+}
+
+func TestMatchContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	q, err := ParseQuery("auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.MatchContext(ctx, generatePrompts(minShardSize)); !errors.Is(err, context.Canceled) {
+		t.Errorf("MatchContext() error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,317 @@
+package matcher
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+type termKind int
+
+const (
+	termFuzzy termKind = iota
+	termExact
+	termPrefix
+	termSuffix
+	termEqual
+)
+
+const (
+	exactRuneBonus = 8
+	boundaryBonus  = 10
+	orSeparator    = "|"
+	inversePrefix  = "!"
+	exactPrefix    = "'"
+	anchorPrefix   = "^"
+	anchorSuffix   = "$"
+)
+
+type term struct {
+	kind    termKind
+	text    string
+	inverse bool
+}
+
+// pattern is an fzf-style extended search pattern: every group must match,
+// and a group matches when any of its terms does.
+type pattern [][]term
+
+// parsePattern splits query into space-separated terms, joining terms around a
+// lone "|" into a single OR group. Terms are fuzzy by default; 'exact, ^prefix,
+// suffix$ and ^equal$ match literally, and a leading ! inverts an exact match.
+func parsePattern(query string) pattern {
+	var (
+		groups pattern
+		joinOR bool
+	)
+
+	for _, field := range strings.Fields(query) {
+		if field == orSeparator {
+			joinOR = len(groups) > 0
+			continue
+		}
+
+		t, ok := parseTerm(field)
+		if !ok {
+			continue
+		}
+
+		if joinOR {
+			last := len(groups) - 1
+			groups[last] = append(groups[last], t)
+		} else {
+			groups = append(groups, []term{t})
+		}
+		joinOR = false
+	}
+
+	return groups
+}
+
+func parseTerm(field string) (term, bool) {
+	t := term{kind: termFuzzy}
+
+	if strings.HasPrefix(field, inversePrefix) {
+		t.inverse = true
+		t.kind = termExact
+		field = field[len(inversePrefix):]
+	}
+
+	switch {
+	case strings.HasPrefix(field, exactPrefix):
+		t.kind = termExact
+		field = field[len(exactPrefix):]
+	case strings.HasPrefix(field, anchorPrefix):
+		t.kind = termPrefix
+		field = field[len(anchorPrefix):]
+		if strings.HasSuffix(field, anchorSuffix) && len(field) > len(anchorSuffix) {
+			t.kind = termEqual
+			field = field[:len(field)-len(anchorSuffix)]
+		}
+	case strings.HasSuffix(field, anchorSuffix) && len(field) > len(anchorSuffix):
+		t.kind = termSuffix
+		field = field[:len(field)-len(anchorSuffix)]
+	}
+
+	t.text = strings.ToLower(field)
+	return t, t.text != ""
+}
+
+// match scores text against every group, matching plain terms with m and
+// summing the best score of each group and collecting the rune positions that
+// term matched. The final result is false as soon as a group has no matching
+// term.
+func (p pattern) match(text string, m Matcher) (int, []int, bool) {
+	var lower string
+	if p.literal() {
+		lower = strings.ToLower(text)
+	}
+	total := 0
+	var positions []int
+
+	for _, group := range p {
+		best, bestPositions, matched := 0, []int(nil), false
+		for _, t := range group {
+			score, termPositions, ok := t.match(text, lower, m)
+			if ok && (!matched || score > best) {
+				best, bestPositions, matched = score, termPositions, true
+			}
+		}
+		if !matched {
+			return 0, nil, false
+		}
+		total += best
+		positions = append(positions, bestPositions...)
+	}
+
+	return total, normalizePositions(positions), true
+}
+
+// literal reports whether any term matches literally, and so needs the
+// lower-cased text. Matchers fold case themselves.
+func (p pattern) literal() bool {
+	for _, group := range p {
+		for _, t := range group {
+			if t.kind != termFuzzy {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (t term) match(text, lower string, m Matcher) (int, []int, bool) {
+	if t.kind == termFuzzy {
+		return m.Match(text, t.text)
+	}
+
+	index := -1
+	switch t.kind {
+	case termExact:
+		index = strings.Index(lower, t.text)
+	case termPrefix:
+		if strings.HasPrefix(lower, t.text) {
+			index = 0
+		}
+	case termSuffix:
+		if strings.HasSuffix(lower, t.text) {
+			index = len(lower) - len(t.text)
+		}
+	case termEqual:
+		if lower == t.text {
+			index = 0
+		}
+	}
+
+	if t.inverse {
+		return 0, nil, index < 0
+	}
+	if index < 0 {
+		return 0, nil, false
+	}
+	return exactScore(lower, t.text, index), t.span(lower, index), true
+}
+
+// span returns the rune positions covered by the term when it occurs at byte
+// index of lower. strings.ToLower maps rune for rune, so these are positions
+// in the original text too.
+func (t term) span(lower string, index int) []int {
+	start := utf8.RuneCountInString(lower[:index])
+	positions := make([]int, utf8.RuneCountInString(t.text))
+	for i := range positions {
+		positions[i] = start + i
+	}
+	return positions
+}
+
+// highlights returns the rune positions of every occurrence of the pattern's
+// terms in text, for highlighting a whole document rather than ranking it.
+// Plain terms that never occur literally fall back to m's match.
+func (p pattern) highlights(text string, m Matcher) []int {
+	lower := strings.ToLower(text)
+	var positions []int
+
+	for _, group := range p {
+		for _, t := range group {
+			if t.inverse {
+				continue
+			}
+
+			var found []int
+			if t.kind == termExact || t.kind == termFuzzy {
+				for offset := 0; offset < len(lower); {
+					index := strings.Index(lower[offset:], t.text)
+					if index < 0 {
+						break
+					}
+					found = append(found, t.span(lower, offset+index)...)
+					offset += index + len(t.text)
+				}
+			}
+			if len(found) == 0 {
+				_, found, _ = t.match(text, lower, m)
+			}
+			positions = append(positions, found...)
+		}
+	}
+
+	return normalizePositions(positions)
+}
+
+func (p pattern) implies(prev pattern, m Matcher) bool {
+	for _, group := range prev {
+		if !slices.ContainsFunc(p, func(g []term) bool { return groupImplies(g, group, m) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// groupImplies reports whether a match of group is always a match of prev,
+// which holds when each of group's alternatives implies one of prev's.
+func groupImplies(group, prev []term, m Matcher) bool {
+	for _, t := range group {
+		if !slices.ContainsFunc(prev, func(p term) bool { return t.implies(p, m) }) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t term) implies(prev term, m Matcher) bool {
+	if t.inverse || prev.inverse {
+		return t == prev
+	}
+
+	switch prev.kind {
+	case termFuzzy:
+		return m.Implies(t.text, t.kind != termFuzzy, prev.text)
+	case termExact:
+		return t.kind != termFuzzy && strings.Contains(t.text, prev.text)
+	case termPrefix:
+		return (t.kind == termPrefix || t.kind == termEqual) && strings.HasPrefix(t.text, prev.text)
+	case termSuffix:
+		return (t.kind == termSuffix || t.kind == termEqual) && strings.HasSuffix(t.text, prev.text)
+	case termEqual:
+		return t == prev
+	}
+	return false
+}
+
+func isSubsequence(sub, s string) bool {
+	rest := []rune(sub)
+	for _, r := range s {
+		if len(rest) == 0 {
+			break
+		}
+		if r == rest[0] {
+			rest = rest[1:]
+		}
+	}
+	return len(rest) == 0
+}
+
+// runeIndexes converts ascending byte offsets into text to rune positions.
+func runeIndexes(text string, offsets []int) []int {
+	positions := make([]int, 0, len(offsets))
+	runeIndex := 0
+	for byteIndex := range text {
+		if len(positions) == len(offsets) {
+			break
+		}
+		if byteIndex == offsets[len(positions)] {
+			positions = append(positions, runeIndex)
+		}
+		runeIndex++
+	}
+	return positions
+}
+
+func normalizePositions(positions []int) []int {
+	if len(positions) == 0 {
+		return nil
+	}
+	sort.Ints(positions)
+	return slices.Compact(positions)
+}
+
+// exactScore rates a literal match on the same scale as the fuzzy scorer:
+// a bonus per matched rune, a bonus for starting at a word boundary, and a
+// penalty for every unmatched rune.
+func exactScore(text, match string, index int) int {
+	runes := utf8.RuneCountInString(match)
+	score := runes*exactRuneBonus - (utf8.RuneCountInString(text) - runes)
+	if index == 0 || isBoundary(text[index-1]) {
+		score += boundaryBonus
+	}
+	return score
+}
+
+func isBoundary(b byte) bool {
+	switch b {
+	case ' ', '\t', '\n', '/', '\\', '-', '_', '.', ':', '(', '"', '\'':
+		return true
+	}
+	return false
+}
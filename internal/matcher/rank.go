@@ -0,0 +1,221 @@
+package matcher
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SortMode int
+
+const (
+	SortRelevance SortMode = iota
+	SortRecency
+	SortFrequency
+	SortAlphabetical
+)
+
+var sortModeNames = []string{"relevance", "recency", "frequency", "alphabetical"}
+
+func (m SortMode) String() string {
+	if m < 0 || int(m) >= len(sortModeNames) {
+		return "unknown"
+	}
+	return sortModeNames[m]
+}
+
+// Next returns the sort mode that follows m, wrapping back to relevance.
+func (m SortMode) Next() SortMode {
+	return (m + 1) % SortMode(len(sortModeNames))
+}
+
+func ParseSortMode(name string) (SortMode, error) {
+	for i, modeName := range sortModeNames {
+		if strings.EqualFold(name, modeName) {
+			return SortMode(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown sort mode %q (want %s)", name, strings.Join(sortModeNames, ", "))
+}
+
+// Boosts maps a prompt's Hash to how strongly past selections favour it for
+// the current query.
+type Boosts map[string]float64
+
+// Weights controls how relevance ranking blends the fuzzy score, the age of a
+// prompt, how often it was reused and how often it was picked in fpf. Each
+// component is scaled to [0, 1] before weighting.
+type Weights struct {
+	Relevance float64
+	Recency   float64
+	Frequency float64
+	Selected  float64
+	// HalfLife is the age at which a prompt's recency component halves.
+	HalfLife time.Duration
+}
+
+var DefaultWeights = Weights{
+	Relevance: 1,
+	Recency:   0.6,
+	Frequency: 0.3,
+	Selected:  0.8,
+	HalfLife:  7 * 24 * time.Hour,
+}
+
+// ParseWeights reads comma-separated overrides of DefaultWeights, e.g.
+// "relevance=1,recency=0.5,frequency=0,selected=1,half-life=72h".
+func ParseWeights(value string) (Weights, error) {
+	w := DefaultWeights
+
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		name, raw, ok := strings.Cut(field, "=")
+		if !ok {
+			return Weights{}, fmt.Errorf("invalid weight %q: want name=value", field)
+		}
+		name, raw = strings.TrimSpace(name), strings.TrimSpace(raw)
+
+		if name == "half-life" {
+			halfLife, err := time.ParseDuration(raw)
+			if err != nil || halfLife <= 0 {
+				return Weights{}, fmt.Errorf("invalid half-life %q: want a positive duration such as 168h", raw)
+			}
+			w.HalfLife = halfLife
+			continue
+		}
+
+		weight, err := strconv.ParseFloat(raw, 64)
+		if err != nil || weight < 0 || math.IsInf(weight, 0) {
+			return Weights{}, fmt.Errorf("invalid %s weight %q: want a non-negative number", name, raw)
+		}
+		switch name {
+		case "relevance":
+			w.Relevance = weight
+		case "recency":
+			w.Recency = weight
+		case "frequency":
+			w.Frequency = weight
+		case "selected":
+			w.Selected = weight
+		default:
+			return Weights{}, fmt.Errorf("unknown weight %q (want relevance, recency, frequency, selected or half-life)", name)
+		}
+	}
+
+	return w, nil
+}
+
+// Scores returns the blended score of every match. Fuzzy scores are
+// normalized against the best and worst match in the set, age decays
+// exponentially with the half-life, reuse grows logarithmically relative to
+// the most reused prompt, and boosts are relative to the largest one.
+func (w Weights) Scores(matches []Match, boosts Boosts, now time.Time) []float64 {
+	scores, _ := w.ScoresContext(context.Background(), matches, boosts, now)
+	return scores
+}
+
+// ScoresContext is Scores, returning ctx's error if it is cancelled before
+// scoring finishes.
+func (w Weights) ScoresContext(ctx context.Context, matches []Match, boosts Boosts, now time.Time) ([]float64, error) {
+	if len(matches) == 0 {
+		return nil, nil
+	}
+
+	minScore, maxScore := matches[0].Score, matches[0].Score
+	maxUses := 1
+	maxBoost := 0.0
+	for _, boost := range boosts {
+		maxBoost = max(maxBoost, boost)
+	}
+	for _, m := range matches {
+		minScore = min(minScore, m.Score)
+		maxScore = max(maxScore, m.Score)
+		maxUses = max(maxUses, m.Prompt.UseCount())
+	}
+
+	scores := make([]float64, len(matches))
+	for i, m := range matches {
+		if i%cancelCheckInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		relevance := 1.0
+		if maxScore > minScore {
+			relevance = float64(m.Score-minScore) / float64(maxScore-minScore)
+		}
+
+		recency := 0.0
+		if m.Prompt.Timestamp > 0 && w.HalfLife > 0 {
+			age := max(now.Sub(time.UnixMilli(m.Prompt.Timestamp)), 0)
+			recency = math.Exp2(-float64(age) / float64(w.HalfLife))
+		}
+
+		frequency := 0.0
+		if maxUses > 1 {
+			frequency = math.Log2(float64(m.Prompt.UseCount())) / math.Log2(float64(maxUses))
+		}
+
+		selected := 0.0
+		if maxBoost > 0 {
+			selected = boosts[m.Prompt.Hash()] / maxBoost
+		}
+
+		scores[i] = w.Relevance*relevance + w.Recency*recency + w.Frequency*frequency + w.Selected*selected
+	}
+	return scores, nil
+}
+
+// Rank orders matches in place for mode. Ties keep their existing order.
+func Rank(matches []Match, mode SortMode, weights Weights, boosts Boosts, now time.Time) {
+	_ = RankContext(context.Background(), matches, mode, weights, boosts, now)
+}
+
+// RankContext is Rank, returning ctx's error if it is cancelled before ranking
+// finishes.
+func RankContext(ctx context.Context, matches []Match, mode SortMode, weights Weights, boosts Boosts, now time.Time) error {
+	switch mode {
+	case SortRelevance:
+		scores, err := weights.ScoresContext(ctx, matches, boosts, now)
+		if err != nil {
+			return err
+		}
+		order := make([]int, len(matches))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return scores[order[i]] > scores[order[j]]
+		})
+
+		ranked := make([]Match, len(matches))
+		for i, index := range order {
+			ranked[i] = matches[index]
+		}
+		copy(matches, ranked)
+	case SortRecency:
+		sort.SliceStable(matches, func(i, j int) bool {
+			return matches[i].Prompt.Timestamp > matches[j].Prompt.Timestamp
+		})
+	case SortFrequency:
+		sort.SliceStable(matches, func(i, j int) bool {
+			a, b := matches[i].Prompt, matches[j].Prompt
+			if a.UseCount() != b.UseCount() {
+				return a.UseCount() > b.UseCount()
+			}
+			return a.Timestamp > b.Timestamp
+		})
+	case SortAlphabetical:
+		sort.SliceStable(matches, func(i, j int) bool {
+			return strings.ToLower(matches[i].Prompt.Display) < strings.ToLower(matches[j].Prompt.Display)
+		})
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,201 @@
+package matcher
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"fpf/pkg/models"
+)
+
+func TestParseWeights(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Weights
+		err      string
+	}{
+		{
+			input:    "",
+			expected: DefaultWeights,
+		},
+		{
+			input:    "recency=0.5, frequency=0",
+			expected: Weights{Relevance: 1, Recency: 0.5, Frequency: 0, Selected: DefaultWeights.Selected, HalfLife: DefaultWeights.HalfLife},
+		},
+		{
+			input:    "relevance=2,selected=0,half-life=72h",
+			expected: Weights{Relevance: 2, Recency: DefaultWeights.Recency, Frequency: DefaultWeights.Frequency, HalfLife: 72 * time.Hour},
+		},
+		{input: "recency", err: "invalid weight"},
+		{input: "recency=-1", err: "invalid recency weight"},
+		{input: "half-life=soon", err: "invalid half-life"},
+		{input: "popularity=1", err: "unknown weight"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseWeights(tt.input)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("ParseWeights(%q) error = %v, want %q", tt.input, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseWeights(%q) error = %v", tt.input, err)
+			}
+			if got != tt.expected {
+				t.Errorf("ParseWeights(%q) = %+v, want %+v", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseSortMode(t *testing.T) {
+	for mode := SortRelevance; mode <= SortAlphabetical; mode++ {
+		got, err := ParseSortMode(strings.ToUpper(mode.String()))
+		if err != nil || got != mode {
+			t.Errorf("ParseSortMode(%q) = %v, %v, want %v", mode, got, err, mode)
+		}
+	}
+	if _, err := ParseSortMode("random"); err == nil {
+		t.Error("ParseSortMode(random) error = nil, want an error")
+	}
+	if got := SortAlphabetical.Next(); got != SortRelevance {
+		t.Errorf("SortAlphabetical.Next() = %v, want relevance", got)
+	}
+}
+
+func TestWeightsScores(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	halfLife := 24 * time.Hour
+	at := func(age time.Duration) int64 { return now.Add(-age).UnixMilli() }
+
+	matches := []Match{
+		{Prompt: &models.Prompt{Display: "best match", Timestamp: at(0), Uses: 1}, Score: 30},
+		{Prompt: &models.Prompt{Display: "a day old", Timestamp: at(24 * time.Hour), Uses: 4}, Score: 10},
+		{Prompt: &models.Prompt{Display: "undated"}, Score: 20},
+	}
+	boosts := Boosts{
+		matches[0].Prompt.Hash(): 1,
+		matches[2].Prompt.Hash(): 4,
+	}
+
+	tests := []struct {
+		name     string
+		weights  Weights
+		expected []float64
+	}{
+		{
+			name:     "relevance only",
+			weights:  Weights{Relevance: 1, HalfLife: halfLife},
+			expected: []float64{1, 0, 0.5},
+		},
+		{
+			name:     "recency halves every half-life",
+			weights:  Weights{Recency: 1, HalfLife: halfLife},
+			expected: []float64{1, 0.5, 0},
+		},
+		{
+			name:     "frequency relative to most reused",
+			weights:  Weights{Frequency: 1, HalfLife: halfLife},
+			expected: []float64{0, 1, 0},
+		},
+		{
+			name:     "selections relative to largest boost",
+			weights:  Weights{Selected: 1, HalfLife: halfLife},
+			expected: []float64{0.25, 0, 1},
+		},
+		{
+			name:     "blended",
+			weights:  Weights{Relevance: 2, Recency: 1, Frequency: 0.5, Selected: 2, HalfLife: halfLife},
+			expected: []float64{3.5, 1, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.weights.Scores(matches, boosts, now); !slices.Equal(got, tt.expected) {
+				t.Errorf("Scores() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRank(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	at := func(days int) int64 { return now.AddDate(0, 0, -days).UnixMilli() }
+
+	matches := []Match{
+		{Prompt: &models.Prompt{Display: "deploy staging", Timestamp: at(30), Uses: 12}, Score: 20},
+		{Prompt: &models.Prompt{Display: "Check logs", Timestamp: at(0), Uses: 1}, Score: 18},
+		{Prompt: &models.Prompt{Display: "bump version", Timestamp: at(2), Uses: 3}, Score: 5},
+		{Prompt: &models.Prompt{Display: "add tests", Timestamp: at(1), Uses: 3}, Score: 19},
+	}
+
+	tests := []struct {
+		mode     SortMode
+		weights  Weights
+		boosts   Boosts
+		expected []string
+	}{
+		{
+			mode:     SortRelevance,
+			weights:  DefaultWeights,
+			expected: []string{"add tests", "Check logs", "deploy staging", "bump version"},
+		},
+		{
+			mode:     SortRelevance,
+			weights:  Weights{Relevance: 1},
+			expected: []string{"deploy staging", "add tests", "Check logs", "bump version"},
+		},
+		{
+			mode:     SortRelevance,
+			weights:  DefaultWeights,
+			boosts:   Boosts{matches[0].Prompt.Hash(): 2, matches[2].Prompt.Hash(): 1},
+			expected: []string{"deploy staging", "add tests", "Check logs", "bump version"},
+		},
+		{
+			mode:     SortRecency,
+			expected: []string{"Check logs", "add tests", "bump version", "deploy staging"},
+		},
+		{
+			mode:     SortFrequency,
+			expected: []string{"deploy staging", "add tests", "bump version", "Check logs"},
+		},
+		{
+			mode:     SortAlphabetical,
+			expected: []string{"add tests", "bump version", "Check logs", "deploy staging"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode.String(), func(t *testing.T) {
+			ranked := slices.Clone(matches)
+			Rank(ranked, tt.mode, tt.weights, tt.boosts, now)
+
+			var got []string
+			for _, m := range ranked {
+				got = append(got, m.Prompt.Display)
+			}
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("Rank(%v) = %q, want %q", tt.mode, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRankContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	matches := []Match{{Prompt: &models.Prompt{Display: "deploy staging"}, Score: 1}}
+	for _, mode := range []SortMode{SortRelevance, SortRecency} {
+		if err := RankContext(ctx, matches, mode, DefaultWeights, nil, time.Now()); !errors.Is(err, context.Canceled) {
+			t.Errorf("RankContext(%v) with a cancelled context error = %v, want context.Canceled", mode, err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package matcher
+
+import (
+	"fmt"
+	"strings"
+
+	"fpf/internal/index"
+	"fpf/pkg/models"
+)
+
+// Matcher is a strategy for matching the plain terms of a query, the ones
+// written without ', ^, $ or !, against a prompt. Literal terms match the
+// same way whichever strategy is used.
+type Matcher interface {
+	Name() string
+	// Match scores term, which is lower case, against text, returning the
+	// rune positions it matched.
+	Match(text, term string) (score int, positions []int, ok bool)
+	// Implies reports whether every text that term matches, or that contains
+	// term when it is literal, is also matched by prev.
+	Implies(term string, literal bool, prev string) bool
+	// Candidates returns the IDs of the texts in snapshot that term could
+	// match, or false when the index can't narrow them down.
+	Candidates(snapshot *index.Snapshot, term string) ([]uint32, bool)
+}
+
+type Strategy int
+
+const (
+	StrategyFuzzy Strategy = iota
+	StrategyBM25
+)
+
+var strategyNames = []string{"fuzzy", "bm25"}
+
+func (s Strategy) String() string {
+	return strategyNames[s]
+}
+
+func (s Strategy) Next() Strategy {
+	return (s + 1) % Strategy(len(strategyNames))
+}
+
+func ParseStrategy(name string) (Strategy, error) {
+	for i, n := range strategyNames {
+		if strings.EqualFold(name, n) {
+			return Strategy(i), nil
+		}
+	}
+	return StrategyFuzzy, fmt.Errorf("unknown matcher %q (want %s)", name, strings.Join(strategyNames, ", "))
+}
+
+// NewMatcher returns the strategy's Matcher for prompts. BM25 computes its
+// statistics over every prompt, so it has to be rebuilt when they change.
+func (s Strategy) NewMatcher(prompts []models.Prompt) Matcher {
+	if s == StrategyBM25 {
+		return NewBM25(prompts)
+	}
+	return Fuzzy{}
+}
+
+// WithMatcher returns q matching its plain terms with m rather than fuzzily.
+func (q Query) WithMatcher(m Matcher) Query {
+	q.matcher = m
+	return q
+}
+
+func (q Query) strategy() Matcher {
+	if q.matcher == nil {
+		return Fuzzy{}
+	}
+	return q.matcher
+}
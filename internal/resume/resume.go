@@ -0,0 +1,96 @@
+package resume
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	DefaultBinary = "claude"
+	BinaryEnvVar  = "FPF_CLAUDE_BIN"
+)
+
+type Target struct {
+	SessionID string
+	Dir       string
+}
+
+type Runner struct {
+	Binary string
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+func DefaultBinaryPath() string {
+	if bin := os.Getenv(BinaryEnvVar); bin != "" {
+		return bin
+	}
+	return DefaultBinary
+}
+
+func NewRunner(binary string) *Runner {
+	return &Runner{
+		Binary: binary,
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+}
+
+func Args(target Target) []string {
+	return []string{"--resume", target.SessionID}
+}
+
+func (r *Runner) Command(target Target) (*exec.Cmd, error) {
+	if target.SessionID == "" {
+		return nil, fmt.Errorf("prompt has no session ID")
+	}
+
+	path, err := exec.LookPath(r.Binary)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find %s: %w", r.Binary, err)
+	}
+
+	cmd := exec.Command(path, Args(target)...)
+	cmd.Dir = target.Dir
+	cmd.Stdin = r.Stdin
+	cmd.Stdout = r.Stdout
+	cmd.Stderr = r.Stderr
+	return cmd, nil
+}
+
+// Run starts the session in the foreground and waits for it to exit. An
+// *exec.ExitError is returned when the session exits with a non-zero status.
+func (r *Runner) Run(target Target) error {
+	cmd, err := r.Command(target)
+	if err != nil {
+		return err
+	}
+	return cmd.Run()
+}
+
+func (r *Runner) ShellCommand(target Target) string {
+	parts := []string{quote(r.Binary)}
+	for _, arg := range Args(target) {
+		parts = append(parts, quote(arg))
+	}
+
+	command := strings.Join(parts, " ")
+	if target.Dir == "" {
+		return command
+	}
+	return "cd " + quote(target.Dir) + " && " + command
+}
+
+func quote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./=:@%+,", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,104 @@
+package resume
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func writeFakeBinary(t *testing.T, script string) string {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("fake binary requires a POSIX shell")
+	}
+
+	path := filepath.Join(t.TempDir(), "fake-claude")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatalf("Failed to write fake binary: %v", err)
+	}
+	return path
+}
+
+func TestRunnerRun(t *testing.T) {
+	bin := writeFakeBinary(t, `echo "$(pwd) $@"`+"\n")
+	dir := t.TempDir()
+
+	var stdout bytes.Buffer
+	runner := NewRunner(bin)
+	runner.Stdout = &stdout
+
+	if err := runner.Run(Target{SessionID: "abc-123", Dir: dir}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	wantDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatalf("EvalSymlinks() error = %v", err)
+	}
+	if got, want := strings.TrimSpace(stdout.String()), wantDir+" --resume abc-123"; got != want {
+		t.Errorf("Run() output = %q, want %q", got, want)
+	}
+}
+
+func TestRunnerRunExitStatus(t *testing.T) {
+	bin := writeFakeBinary(t, "exit 3\n")
+
+	runner := NewRunner(bin)
+	runner.Stdout, runner.Stderr = &bytes.Buffer{}, &bytes.Buffer{}
+
+	var exitErr *exec.ExitError
+	if err := runner.Run(Target{SessionID: "abc"}); !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("Run() error = %v, want exit status 3", err)
+	}
+}
+
+func TestRunnerErrors(t *testing.T) {
+	if err := NewRunner("true").Run(Target{}); err == nil {
+		t.Error("Run() without session ID returned nil error")
+	}
+	if err := NewRunner(filepath.Join(t.TempDir(), "missing")).Run(Target{SessionID: "abc"}); err == nil {
+		t.Error("Run() with missing binary returned nil error")
+	}
+}
+
+func TestShellCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		binary   string
+		target   Target
+		expected string
+	}{
+		{
+			name:     "plain",
+			binary:   "claude",
+			target:   Target{SessionID: "abc-123", Dir: "/home/user/app"},
+			expected: "cd /home/user/app && claude --resume abc-123",
+		},
+		{
+			name:     "needs quoting",
+			binary:   "/opt/my tools/claude",
+			target:   Target{SessionID: "abc", Dir: "/home/user/it's here"},
+			expected: `cd '/home/user/it'\''s here' && '/opt/my tools/claude' --resume abc`,
+		},
+		{
+			name:     "no directory",
+			binary:   "claude",
+			target:   Target{SessionID: "abc"},
+			expected: "claude --resume abc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewRunner(tt.binary).ShellCommand(tt.target); got != tt.expected {
+				t.Errorf("ShellCommand() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package selections
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fpf/internal/matcher"
+)
+
+const (
+	FileName = "selections.jsonl"
+
+	// decayHalfLife is how long it takes for a selection to count half as much.
+	decayHalfLife = 30 * 24 * time.Hour
+	// baseSimilarity is how much a selection counts for an unrelated query, so
+	// prompts that are picked often float up everywhere, just less so.
+	baseSimilarity = 0.25
+)
+
+// Selection records a prompt picked in fpf. Only the prompt's hash is kept,
+// alongside the query that found it.
+type Selection struct {
+	Hash      string `json:"hash"`
+	Timestamp int64  `json:"timestamp"`
+	Query     string `json:"query"`
+}
+
+func GetStateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "fpf"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".local", "state", "fpf"), nil
+}
+
+// Store is an append-only log of selections. Each record is written with a
+// single append, so several fpf processes can record at the same time
+// without interleaving lines.
+type Store struct {
+	Path string
+}
+
+func NewStore(path string) *Store {
+	return &Store{Path: path}
+}
+
+func OpenStore() (*Store, error) {
+	stateDir, err := GetStateDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewStore(filepath.Join(stateDir, FileName)), nil
+}
+
+func (s *Store) Record(selection Selection) error {
+	line, err := json.Marshal(selection)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Load returns every recorded selection, skipping lines that can't be parsed.
+func (s *Store) Load() ([]Selection, error) {
+	file, err := os.Open(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var selections []Selection
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var selection Selection
+		if err := json.Unmarshal(scanner.Bytes(), &selection); err != nil || selection.Hash == "" {
+			continue
+		}
+		selections = append(selections, selection)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", s.Path, err)
+	}
+
+	return selections, nil
+}
+
+// Forget deletes every recorded selection.
+func (s *Store) Forget() error {
+	if err := os.Remove(s.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Boosts weighs each selection by how similar its query was to query and by
+// how long ago it was made, summing them per prompt.
+func Boosts(selections []Selection, query string, now time.Time) matcher.Boosts {
+	if len(selections) == 0 {
+		return nil
+	}
+
+	terms := queryTerms(query)
+	boosts := make(matcher.Boosts)
+	for _, selection := range selections {
+		age := max(now.Sub(time.UnixMilli(selection.Timestamp)), 0)
+		decay := math.Exp2(-float64(age) / float64(decayHalfLife))
+		similarity := baseSimilarity + (1-baseSimilarity)*termOverlap(terms, queryTerms(selection.Query))
+		boosts[selection.Hash] += decay * similarity
+	}
+	return boosts
+}
+
+func queryTerms(query string) []string {
+	return strings.Fields(strings.ToLower(query))
+}
+
+// termOverlap is the share of terms the two queries have in common, where a
+// term also matches any term it is a prefix of, so a query that is still
+// being typed matches the finished one.
+func termOverlap(a, b []string) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for _, x := range a {
+		for _, y := range b {
+			if strings.HasPrefix(x, y) || strings.HasPrefix(y, x) {
+				shared++
+				break
+			}
+		}
+	}
+	return float64(shared) / float64(max(len(a), len(b)))
+}
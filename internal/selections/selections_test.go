@@ -0,0 +1,124 @@
+package selections
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStoreRecordLoadForget(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "state", FileName))
+
+	got, err := store.Load()
+	if err != nil || got != nil {
+		t.Fatalf("Load() on missing file = %v, %v, want nil, nil", got, err)
+	}
+
+	want := []Selection{
+		{Hash: "a1", Timestamp: 1, Query: "deploy"},
+		{Hash: "b2", Timestamp: 2, Query: "%p api tests"},
+	}
+	for _, selection := range want {
+		if err := store.Record(selection); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	file, err := os.OpenFile(store.Path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("{\"hash\": \"trunc\n")
+	file.Close()
+
+	got, err = store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load() = %v, want %v", got, want)
+	}
+
+	if err := store.Forget(); err != nil {
+		t.Fatalf("Forget() error = %v", err)
+	}
+	if err := store.Forget(); err != nil {
+		t.Errorf("Forget() twice error = %v", err)
+	}
+	if got, _ := store.Load(); got != nil {
+		t.Errorf("Load() after Forget() = %v, want nil", got)
+	}
+}
+
+func TestStoreConcurrentRecord(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), FileName))
+
+	const writers, records = 8, 50
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < records; i++ {
+				if err := store.Record(Selection{Hash: "hash", Timestamp: int64(w*records + i), Query: "a fairly long query to widen each write"}); err != nil {
+					t.Errorf("Record() error = %v", err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	got, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(got) != writers*records {
+		t.Errorf("Load() returned %d selections, want %d", len(got), writers*records)
+	}
+}
+
+func TestBoosts(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	at := func(age time.Duration) int64 { return now.Add(-age).UnixMilli() }
+
+	selections := []Selection{
+		{Hash: "deploy", Timestamp: at(0), Query: "deploy staging"},
+		{Hash: "deploy", Timestamp: at(0), Query: "deploy"},
+		{Hash: "tests", Timestamp: at(0), Query: "run tests"},
+		{Hash: "old", Timestamp: at(decayHalfLife), Query: "deploy staging"},
+	}
+
+	tests := []struct {
+		query    string
+		expected map[string]float64
+	}{
+		{
+			query:    "dep",
+			expected: map[string]float64{"deploy": 0.625 + 1, "tests": 0.25, "old": 0.3125},
+		},
+		{
+			query:    "DEPLOY staging",
+			expected: map[string]float64{"deploy": 1 + 0.625, "tests": 0.25, "old": 0.5},
+		},
+		{
+			query:    "",
+			expected: map[string]float64{"deploy": 0.5, "tests": 0.25, "old": 0.125},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got := Boosts(selections, tt.query, now)
+			if !reflect.DeepEqual(map[string]float64(got), tt.expected) {
+				t.Errorf("Boosts(%q) = %v, want %v", tt.query, got, tt.expected)
+			}
+		})
+	}
+
+	if got := Boosts(nil, "deploy", now); got != nil {
+		t.Errorf("Boosts(nil) = %v, want nil", got)
+	}
+}
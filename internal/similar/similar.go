@@ -0,0 +1,132 @@
+package similar
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"fpf/pkg/models"
+)
+
+// minScore leaves out prompts that share little more than common words.
+const minScore = 0.1
+
+// Result is a prompt similar to the one searched for, with its index in the
+// prompts the Engine was built from and their cosine similarity, from 0 to 1.
+type Result struct {
+	Prompt *models.Prompt
+	Index  int
+	Score  float64
+}
+
+type posting struct {
+	doc    int32
+	weight float32
+}
+
+// Engine finds similar prompts by the cosine similarity of their TF-IDF word
+// vectors, so prompts sharing rare words score higher than prompts sharing
+// common ones. It works entirely from the prompts it is built from.
+type Engine struct {
+	prompts  []models.Prompt
+	words    map[string]int
+	idf      []float64
+	postings [][]posting
+}
+
+func NewEngine(prompts []models.Prompt) *Engine {
+	e := &Engine{prompts: prompts, words: make(map[string]int)}
+
+	var docFreq []int
+	seen := make(map[int]bool)
+	for i := range prompts {
+		clear(seen)
+		for _, word := range tokenize(prompts[i].Display) {
+			id, ok := e.words[word]
+			if !ok {
+				id = len(docFreq)
+				e.words[word] = id
+				docFreq = append(docFreq, 0)
+			}
+			if !seen[id] {
+				seen[id] = true
+				docFreq[id]++
+			}
+		}
+	}
+
+	e.idf = make([]float64, len(docFreq))
+	for id, df := range docFreq {
+		e.idf[id] = math.Log(1 + float64(len(prompts))/float64(df))
+	}
+
+	e.postings = make([][]posting, len(docFreq))
+	for i := range prompts {
+		for id, weight := range e.vector(prompts[i].Display) {
+			e.postings[id] = append(e.postings[id], posting{doc: int32(i), weight: float32(weight)})
+		}
+	}
+	return e
+}
+
+// Similar returns up to limit prompts most similar to text, best first.
+// Prompts with the same text are left out.
+func (e *Engine) Similar(text string, limit int) []Result {
+	scores := make(map[int32]float64)
+	for id, weight := range e.vector(text) {
+		for _, p := range e.postings[id] {
+			scores[p.doc] += weight * float64(p.weight)
+		}
+	}
+
+	var results []Result
+	for doc, score := range scores {
+		if score < minScore || e.prompts[doc].Display == text {
+			continue
+		}
+		results = append(results, Result{Prompt: &e.prompts[doc], Index: int(doc), Score: min(score, 1)})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Index < results[j].Index
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// vector weighs each known word of text by 1+ln(tf) times its idf, scaled to
+// unit length.
+func (e *Engine) vector(text string) map[int]float64 {
+	tf := make(map[int]int)
+	for _, word := range tokenize(text) {
+		if id, ok := e.words[word]; ok {
+			tf[id]++
+		}
+	}
+
+	vector := make(map[int]float64, len(tf))
+	var norm float64
+	for id, n := range tf {
+		weight := (1 + math.Log(float64(n))) * e.idf[id]
+		vector[id] = weight
+		norm += weight * weight
+	}
+
+	norm = math.Sqrt(norm)
+	for id := range vector {
+		vector[id] /= norm
+	}
+	return vector
+}
+
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
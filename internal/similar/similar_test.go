@@ -0,0 +1,46 @@
+package similar
+
+import (
+	"testing"
+
+	"fpf/pkg/models"
+)
+
+func TestSimilar(t *testing.T) {
+	prompts := []models.Prompt{
+		{Display: "add rate limiting to the login handler"},
+		{Display: "fix the flaky websocket test"},
+		{Display: "Add rate limiting to the signup handler"},
+		{Display: "add rate limiting to the login handler"},
+		{Display: "update the readme"},
+		{Display: "rate limiting for login, take two"},
+	}
+	e := NewEngine(prompts)
+
+	results := e.Similar(prompts[0].Display, 10)
+
+	var got []int
+	for _, r := range results {
+		got = append(got, r.Index)
+		if r.Score <= 0 || r.Score > 1 {
+			t.Errorf("result %d has score %v, want within (0, 1]", r.Index, r.Score)
+		}
+		if r.Prompt != &prompts[r.Index] {
+			t.Errorf("result %d points at the wrong prompt", r.Index)
+		}
+	}
+
+	if len(got) != 2 || got[0] != 2 || got[1] != 5 {
+		t.Fatalf("Similar() returned prompts %v, want [2 5]", got)
+	}
+	if results[0].Score <= results[1].Score {
+		t.Errorf("Similar() scores %v and %v are not in descending order", results[0].Score, results[1].Score)
+	}
+
+	if got := e.Similar(prompts[0].Display, 1); len(got) != 1 {
+		t.Errorf("Similar() with limit 1 returned %d results", len(got))
+	}
+	if got := e.Similar("completely unrelated words", 10); len(got) != 0 {
+		t.Errorf("Similar() of unknown words returned %d results, want none", len(got))
+	}
+}
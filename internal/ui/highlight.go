@@ -0,0 +1,54 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	matchStyle         = lipgloss.NewStyle().Foreground(accentColor).Bold(true)
+	selectedMatchStyle = matchStyle.Underline(true)
+)
+
+// highlightRunes renders text with the runes at positions (ascending rune
+// indexes) in match and everything else in base. Each run is styled on its
+// own so that highlights don't reset the surrounding style.
+func highlightRunes(text string, positions []int, base, match lipgloss.Style) string {
+	if len(positions) == 0 {
+		return base.Render(text)
+	}
+
+	var (
+		b           strings.Builder
+		run         []rune
+		highlighted bool
+		next        int
+	)
+
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		style := base
+		if highlighted {
+			style = match
+		}
+		b.WriteString(style.Render(string(run)))
+		run = run[:0]
+	}
+
+	for i, r := range []rune(text) {
+		for next < len(positions) && positions[next] < i {
+			next++
+		}
+		if isMatch := next < len(positions) && positions[next] == i; isMatch != highlighted {
+			flush()
+			highlighted = isMatch
+		}
+		run = append(run, r)
+	}
+	flush()
+
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package ui
+
+import (
+	"testing"
+
+	"fpf/pkg/models"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// bracketStyle marks highlighted runs without relying on the terminal's
+// colour support.
+var bracketStyle = lipgloss.NewStyle().Transform(func(s string) string { return "[" + s + "]" })
+
+func TestHighlightTruncatedTitle(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		positions []int
+		width     int
+		want      string
+	}{
+		{"fits", "fix the login bug", []int{0, 1, 2, 4}, 20, "[fix] [t]he login bug"},
+		{"no positions", "fix the login bug", nil, 20, "fix the login bug"},
+		{"truncated", "fix the login bug", []int{0, 8, 9}, 10, "[f]ix the [l]…"},
+		{"match at the cut", "fix the login bug", []int{0, 9, 10}, 10, "[f]ix the l…"},
+		{"match before the cut", "fix the login bug", []int{8, 9}, 10, "fix the [l]…"},
+		{"wide runes", "日本語のテキスト", []int{0, 3, 4}, 9, "[日]本語[の]…"},
+		{"wide rune at the cut", "日本語のテキスト", []int{4}, 9, "日本語の…"},
+		{"first line", "fix it\nplease", []int{0, 6, 7}, 20, "[f]ix it…"},
+		{"first line truncated", "fix the login bug\nplease", []int{2, 12}, 10, "fi[x] the l…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, positions := truncateTitle(tt.text, tt.positions, tt.width)
+			if width := lipgloss.Width(text); width > tt.width {
+				t.Errorf("truncateTitle() = %q is %d wide, want at most %d", text, width, tt.width)
+			}
+			if got := highlightRunes(text, positions, lipgloss.NewStyle(), bracketStyle); got != tt.want {
+				t.Errorf("highlighted title = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderItemWidth(t *testing.T) {
+	prompt := &models.Prompt{Display: "日本語のテキストを翻訳して and explain the grammar\nin detail", Project: "/a"}
+	for _, selected := range []bool{false, true} {
+		for _, width := range []int{12, 20, 40} {
+			title, _ := renderItem(item{prompt: prompt, positions: []int{0, 1, 30, 50}}, selected, width)
+			if got := lipgloss.Width(title); got > width {
+				t.Errorf("renderItem(selected=%v) title %q is %d wide, want at most %d", selected, title, got, width)
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package ui
+
+import (
+	"fpf/pkg/models"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type promptsMergedMsg struct {
+	prompts []models.Prompt
+}
+
+// startMerge folds the prompts that arrived while running into the ones
+// shown, in the background as grouping them can take a while. Merges run one
+// at a time, each building on the last, so prompts arriving meanwhile wait
+// for the next one.
+func (m *Model) startMerge() tea.Cmd {
+	if m.merging || len(m.pendingPrompts) == 0 {
+		return nil
+	}
+	m.merging = true
+
+	merge, existing, incoming := m.merge, m.allPrompts, m.pendingPrompts
+	m.pendingPrompts = nil
+	return func() tea.Msg {
+		return promptsMergedMsg{prompts: merge(existing, incoming)}
+	}
+}
+
+// groupPrompts groups the prompts shown in the background, applying the
+// result like a merge.
+func (m *Model) groupPrompts() tea.Cmd {
+	if m.group == nil {
+		return nil
+	}
+
+	group, prompts := m.group, m.allPrompts
+	return func() tea.Msg {
+		return promptsMergedMsg{prompts: group(prompts)}
+	}
+}
+
+func (m *Model) applyMerge(msg promptsMergedMsg) tea.Cmd {
+	m.merging = false
+	m.allPrompts = msg.prompts
+	m.lastMatches = nil
+	m.matcher = nil
+	m.similar = nil
+	m.promptsVersion++
+	m.snapshot = nil
+	return tea.Batch(m.restartSearch(true), m.bindIndex(), m.startMerge())
+}
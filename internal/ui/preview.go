@@ -0,0 +1,159 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"fpf/pkg/models"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	previewLabelStyle   = lipgloss.NewStyle().Foreground(mutedColor).Width(10)
+	previewValueStyle   = lipgloss.NewStyle().Foreground(lightMutedColor)
+	previewRuleStyle    = lipgloss.NewStyle().Foreground(separatorColor)
+	previewSectionStyle = lipgloss.NewStyle().Bold(true).Foreground(mutedColor)
+)
+
+type metadataField struct {
+	label string
+	value string
+}
+
+func promptMetadata(p models.Prompt) []metadataField {
+	fields := []metadataField{
+		{"Project", p.ProjectPath()},
+		{"Time", p.TimeAgo()},
+		{"Source", p.Source},
+		{"Session", p.SessionID},
+		{"Branch", p.GitBranch},
+		{"Model", p.Model},
+		{"Version", p.Version},
+	}
+	if p.IsSidechain {
+		fields = append(fields, metadataField{"Sidechain", "yes"})
+	}
+	if p.File != "" {
+		location := p.File
+		if p.Line > 0 {
+			location += ":" + strconv.Itoa(p.Line)
+		}
+		fields = append(fields, metadataField{"File", location})
+	}
+	return fields
+}
+
+func renderMetadata(p models.Prompt, width int) string {
+	valueStyle := previewValueStyle.Width(max(width-previewLabelStyle.GetWidth(), 1))
+
+	var lines []string
+	for _, field := range promptMetadata(p) {
+		if field.value == "" {
+			continue
+		}
+		lines = append(lines, lipgloss.JoinHorizontal(lipgloss.Top,
+			previewLabelStyle.Render(field.label),
+			valueStyle.Render(field.value),
+		))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func renderReply(p models.Prompt, width int) string {
+	var parts []string
+	if reply := p.FirstReply(); reply != "" {
+		parts = append(parts, lipgloss.NewStyle().Width(width).Render(reply))
+	}
+	if summary := p.ToolSummary(); summary != "" {
+		parts = append(parts, toolCallStyle.Width(width).Render("▸ "+summary))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return previewSectionStyle.Render("Reply") + "\n" + strings.Join(parts, "\n\n")
+}
+
+func renderFiles(p models.Prompt, width int) string {
+	files := p.TouchedFiles()
+	if len(files) == 0 {
+		return ""
+	}
+
+	lines := make([]string, len(files))
+	for i, file := range files {
+		if rel, err := filepath.Rel(p.Project, file); p.Project != "" && err == nil && !strings.HasPrefix(rel, "..") {
+			file = rel
+		}
+		lines[i] = previewValueStyle.Width(width).Render(file)
+	}
+	return previewSectionStyle.Render("Files") + "\n" + strings.Join(lines, "\n")
+}
+
+// occurrenceTimeFormat is how occurrences show when a prompt was sent, as
+// "3 days ago" can't tell apart several uses on the same day.
+const occurrenceTimeFormat = "2006-01-02 15:04"
+
+func renderOccurrences(p models.Prompt, width int) string {
+	timeline := p.Timeline()
+	if len(timeline) < 2 {
+		return ""
+	}
+
+	lines := make([]string, len(timeline))
+	for i, o := range timeline {
+		line := time.UnixMilli(o.Timestamp).Format(occurrenceTimeFormat) + " • " + o.ProjectPath()
+		if o.SessionID != "" {
+			line += " • " + o.SessionID
+		}
+		lines[i] = previewValueStyle.Width(width).Render(line)
+	}
+	return previewSectionStyle.Render("Occurrences") + "\n" + strings.Join(lines, "\n")
+}
+
+func renderVariants(versions []models.Prompt, current int, width int) string {
+	lines := make([]string, len(versions))
+	for i, v := range versions {
+		marker := "  "
+		if i == current {
+			marker = "> "
+		}
+		lines[i] = previewValueStyle.Width(width).Render(marker + v.Description())
+	}
+	return previewSectionStyle.Render("Variants") + "\n" + strings.Join(lines, "\n")
+}
+
+// renderPreview shows the version of p at variant, 0 being p itself, along
+// with the times and projects of its other variants.
+func renderPreview(p models.Prompt, variant int, highlights []int, width int) string {
+	title := previewTitleStyle.Render("Preview - Press 'esc' to exit")
+	versions := p.Versions()
+	p = versions[variant]
+	content := highlightRunes(p.Display, highlights, lipgloss.NewStyle(), matchStyle)
+	wrappedContent := lipgloss.NewStyle().Width(width).Render(content)
+	rule := previewRuleStyle.Render(strings.Repeat("─", max(width, 1)))
+
+	if len(versions) > 1 {
+		title += "\n" + previewSectionStyle.Render(fmt.Sprintf("Variant %d of %d • n/p to step through", variant+1, len(versions)))
+	}
+
+	sections := []string{wrappedContent}
+	if reply := renderReply(p, width); reply != "" {
+		sections = append(sections, reply)
+	}
+	if files := renderFiles(p, width); files != "" {
+		sections = append(sections, files)
+	}
+	sections = append(sections, renderMetadata(p, width))
+	if occurrences := renderOccurrences(p, width); occurrences != "" {
+		sections = append(sections, occurrences)
+	}
+	if len(versions) > 1 {
+		sections = append(sections, renderVariants(versions, variant, width))
+	}
+
+	return title + "\n\n" + strings.Join(sections, "\n\n"+rule+"\n")
+}
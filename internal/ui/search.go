@@ -0,0 +1,205 @@
+package ui
+
+import (
+	"context"
+	"time"
+
+	"fpf/internal/index"
+	"fpf/internal/matcher"
+	"fpf/pkg/models"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// searchDebounce is how long typing has to pause before a search starts.
+const searchDebounce = 40 * time.Millisecond
+
+type searchDebounceMsg struct {
+	seq int
+}
+
+type indexBoundMsg struct {
+	version  int
+	snapshot *index.Snapshot
+}
+
+type searchResultMsg struct {
+	seq           int
+	query         matcher.Query
+	matcher       matcher.Matcher
+	matches       []matcher.Match
+	items         []list.Item
+	keepSelection bool
+}
+
+// search is everything one run of matching and ranking needs, captured so it
+// can run off the UI goroutine.
+type search struct {
+	seq           int
+	query         matcher.Query
+	text          string
+	prompts       []models.Prompt
+	snapshot      *index.Snapshot
+	previous      []matcher.Match
+	strategy      matcher.Strategy
+	matcher       matcher.Matcher
+	sortMode      matcher.SortMode
+	weights       matcher.Weights
+	boosts        func(query string) matcher.Boosts
+	keepSelection bool
+}
+
+func (s search) run(ctx context.Context) (searchResultMsg, error) {
+	m := s.matcher
+	if m == nil {
+		m = s.strategy.NewMatcher(s.prompts)
+	}
+	query := s.query.WithMatcher(m)
+
+	var (
+		matches []matcher.Match
+		err     error
+	)
+	if s.previous != nil {
+		matches, err = query.Refine(ctx, s.previous)
+	} else {
+		matches, err = query.MatchIndexed(ctx, s.prompts, s.snapshot)
+	}
+	if err != nil {
+		return searchResultMsg{}, err
+	}
+
+	var boosts matcher.Boosts
+	if s.boosts != nil {
+		boosts = s.boosts(s.text)
+	}
+	if err := matcher.RankContext(ctx, matches, s.sortMode, s.weights, boosts, time.Now()); err != nil {
+		return searchResultMsg{}, err
+	}
+
+	return searchResultMsg{
+		seq:           s.seq,
+		query:         query,
+		matcher:       m,
+		matches:       matches,
+		items:         matchesToItems(matches),
+		keepSelection: s.keepSelection,
+	}, nil
+}
+
+func matchesToItems(matches []matcher.Match) []list.Item {
+	items := make([]list.Item, len(matches))
+	for i, match := range matches {
+		items[i] = item{prompt: match.Prompt, positions: match.Positions}
+	}
+	return items
+}
+
+// queryChanged parses the search text straight away, so mistakes are
+// reported while typing, and schedules a search for when typing pauses.
+func (m *Model) queryChanged() tea.Cmd {
+	m.similarTo = nil
+
+	query, err := matcher.ParseQuery(m.filterInput.Value())
+	m.queryErr = err
+	if err != nil {
+		// Keep the last results on screen while the query is being fixed.
+		return nil
+	}
+
+	m.query = query
+	m.searchSeq++
+	seq := m.searchSeq
+	return tea.Tick(searchDebounce, func(time.Time) tea.Msg {
+		return searchDebounceMsg{seq: seq}
+	})
+}
+
+// startSearch cancels any search still running and starts one for the
+// current query in the background.
+func (m *Model) startSearch(keepSelection bool) tea.Cmd {
+	if m.cancelSearch != nil {
+		m.cancelSearch()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelSearch = cancel
+
+	s := m.newSearch(keepSelection)
+	return func() tea.Msg {
+		msg, err := s.run(ctx)
+		if err != nil {
+			return nil
+		}
+		return msg
+	}
+}
+
+// restartSearch searches again straight away, e.g. after the prompts or the
+// sort order changed. While similar prompts are shown, that waits until the
+// search results are shown again.
+func (m *Model) restartSearch(keepSelection bool) tea.Cmd {
+	if m.similarTo != nil {
+		return nil
+	}
+	m.searchSeq++
+	return m.startSearch(keepSelection)
+}
+
+// newSearch reuses the previous results when the current query can only
+// narrow them, e.g. while a term is being typed out. A matcher that is yet
+// to be built for the prompts is built by the search.
+func (m *Model) newSearch(keepSelection bool) search {
+	s := search{
+		seq:           m.searchSeq,
+		query:         m.query,
+		text:          m.filterInput.Value(),
+		prompts:       m.allPrompts,
+		snapshot:      m.snapshot,
+		strategy:      m.strategy,
+		matcher:       m.matcher,
+		sortMode:      m.sortMode,
+		weights:       m.weights,
+		boosts:        m.boosts,
+		keepSelection: keepSelection,
+	}
+	if m.matcher != nil && m.lastMatches != nil && m.query.WithMatcher(m.matcher).Narrows(m.lastQuery) {
+		s.previous = m.lastMatches
+	}
+	return s
+}
+
+// bindIndex brings the index up to date with the prompts in the background.
+// Searches scan every prompt until it is done.
+func (m *Model) bindIndex() tea.Cmd {
+	if m.index == nil {
+		return nil
+	}
+
+	ix, prompts, version := m.index, m.allPrompts, m.promptsVersion
+	return func() tea.Msg {
+		return indexBoundMsg{version: version, snapshot: ix.Bind(prompts)}
+	}
+}
+
+func (m *Model) applySearch(msg searchResultMsg) {
+	if msg.seq != m.searchSeq {
+		return
+	}
+
+	selected, hadSelection := m.list.SelectedItem().(item)
+
+	m.lastQuery, m.lastMatches, m.matcher = msg.query, msg.matches, msg.matcher
+	m.list.SetItems(msg.items)
+	m.list.Select(0)
+
+	if !msg.keepSelection || !hadSelection {
+		return
+	}
+	for i, listItem := range m.list.Items() {
+		if it, ok := listItem.(item); ok && it.prompt.Display == selected.prompt.Display {
+			m.list.Select(i)
+			return
+		}
+	}
+}
@@ -1,11 +1,17 @@
 package ui
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"slices"
+	"sort"
 	"strings"
 
+	"fpf/internal/history"
+	"fpf/internal/index"
 	"fpf/internal/matcher"
+	"fpf/internal/similar"
 	"fpf/pkg/models"
 
 	"github.com/charmbracelet/bubbles/list"
@@ -47,11 +53,18 @@ var (
 		Light: "#0087D7",
 		Dark:  "#5FAFFF",
 	}
+	errorColor = lipgloss.AdaptiveColor{
+		Light: "#D70000",
+		Dark:  "#FF5F5F",
+	}
 
 	itemStyle         = lipgloss.NewStyle().PaddingLeft(4)
-	selectedItemStyle = lipgloss.NewStyle().PaddingLeft(2).Foreground(accentColor)
+	selectedItemStyle = lipgloss.NewStyle().PaddingLeft(2)
+	selectedTextStyle = lipgloss.NewStyle().Foreground(accentColor)
 	projectStyle      = lipgloss.NewStyle().PaddingLeft(4).Foreground(mutedColor)
 	filterInputStyle  = lipgloss.NewStyle().PaddingLeft(2)
+	queryErrorStyle   = lipgloss.NewStyle().PaddingLeft(4).Foreground(errorColor)
+	noticeStyle       = lipgloss.NewStyle().PaddingLeft(4).Foreground(accentColor)
 	paginationStyle   = list.DefaultStyles().PaginationStyle.PaddingLeft(4).PaddingTop(1)
 	helpStyle         = lipgloss.NewStyle().Foreground(mutedColor).PaddingLeft(4).PaddingTop(1)
 	helpKeyStyle      = lipgloss.NewStyle().Foreground(mutedColor)
@@ -64,16 +77,19 @@ var (
 				MarginLeft(2).
 				MarginRight(2)
 	previewTitleStyle = lipgloss.NewStyle().Bold(true).Foreground(accentColor).MarginBottom(1)
-
-	helpText = buildHelpText()
 )
 
-func buildHelpText() string {
+func buildHelpText(sortMode matcher.SortMode, strategy matcher.Strategy) string {
 	sep := " " + helpSepStyle.Render("•") + " "
 	return helpStyle.Render(
 		helpKeyStyle.Render("↑/↓") + " " + helpDescStyle.Render("navigate") + sep +
 			helpKeyStyle.Render("ctrl+p") + " " + helpDescStyle.Render("preview") + sep +
 			helpKeyStyle.Render("enter") + " " + helpDescStyle.Render("select") + sep +
+			helpKeyStyle.Render("ctrl+t") + " " + helpDescStyle.Render("session") + sep +
+			helpKeyStyle.Render("ctrl+r") + " " + helpDescStyle.Render("resume") + sep +
+			helpKeyStyle.Render("ctrl+s") + " " + helpDescStyle.Render("sort: "+sortMode.String()) + sep +
+			helpKeyStyle.Render("ctrl+g") + " " + helpDescStyle.Render("match: "+strategy.String()) + sep +
+			helpKeyStyle.Render("ctrl+l") + " " + helpDescStyle.Render("similar") + sep +
 			helpKeyStyle.Render("esc") + " " + helpDescStyle.Render("quit"),
 	)
 }
@@ -86,12 +102,26 @@ func firstLine(s string) string {
 }
 
 type item struct {
-	prompt models.Prompt
+	prompt     *models.Prompt
+	positions  []int
+	similarity float64
 }
 
 func (i item) FilterValue() string { return i.prompt.Display }
 func (i item) Title() string       { return firstLine(i.prompt.Display) }
-func (i item) Description() string { return i.prompt.Description() }
+func (i item) Description() string {
+	if i.similarity > 0 {
+		return fmt.Sprintf("%.0f%% similar • %s", i.similarity*100, i.description())
+	}
+	return i.description()
+}
+
+func (i item) description() string {
+	if n := len(i.prompt.Variants); n > 0 {
+		return fmt.Sprintf("%s • ×%d variants", i.prompt.Description(), n+1)
+	}
+	return i.prompt.Description()
+}
 
 type itemDelegate struct{}
 
@@ -108,47 +138,141 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, listItem list
 	fmt.Fprintf(w, "%s\n%s", title, desc)
 }
 
-func renderItem(i item, selected bool, width int) (string, string) {
-	titleText := i.Title()
-	availableWidth := width - itemPadding
-
-	if lipgloss.Width(titleText) > availableWidth {
-		runes := []rune(titleText)
-		targetWidth := availableWidth - ellipsisWidth
-		for i := len(runes); i > 0; i-- {
-			candidate := string(runes[:i])
-			if lipgloss.Width(candidate) <= targetWidth {
-				titleText = candidate + "…"
-				break
-			}
+// truncateTitle fits the first line of text into width, ending it with an
+// ellipsis if anything was cut. Positions past the cut are dropped so the
+// ellipsis is never highlighted.
+func truncateTitle(text string, positions []int, width int) (string, []int) {
+	runes := []rune(text)
+	end, cut := len(runes), false
+	if idx := slices.Index(runes, '\n'); idx >= 0 {
+		end, cut = idx, true
+	}
+
+	limit := width
+	if cut {
+		limit -= ellipsisWidth
+	}
+	if lipgloss.Width(string(runes[:end])) > limit {
+		for end > 0 && lipgloss.Width(string(runes[:end])) > width-ellipsisWidth {
+			end--
 		}
+		cut = true
 	}
+	if !cut {
+		return text, positions
+	}
+
+	kept := positions[:sort.SearchInts(positions, end)]
+	return string(runes[:end]) + "…", kept
+}
+
+func renderItem(i item, selected bool, width int) (string, string) {
+	titleText, positions := truncateTitle(i.prompt.Display, i.positions, width-itemPadding)
 
 	var title string
 	if selected {
-		title = selectedItemStyle.Render("> " + titleText)
+		title = selectedItemStyle.Render(selectedTextStyle.Render("> ") +
+			highlightRunes(titleText, positions, selectedTextStyle, selectedMatchStyle))
 	} else {
-		title = itemStyle.Render(titleText)
+		title = itemStyle.Render(highlightRunes(titleText, positions, lipgloss.NewStyle(), matchStyle))
 	}
 	return title, projectStyle.Render(i.Description())
 }
 
+type NewPromptsMsg struct {
+	Prompts []models.Prompt
+}
+
 type Model struct {
-	list        list.Model
-	filterInput textinput.Model
-	viewport    viewport.Model
-	choice      string
-	quitting    bool
-	previewing  bool
-	allPrompts  []models.Prompt
+	list           list.Model
+	filterInput    textinput.Model
+	viewport       viewport.Model
+	choice         string
+	resume         *models.Prompt
+	quitting       bool
+	previewing     bool
+	previewVariant int
+	session        *sessionView
+	loadTranscript TranscriptLoader
+	allPrompts     []models.Prompt
+	group          func(prompts []models.Prompt) []models.Prompt
+	merge          func(existing, incoming []models.Prompt) []models.Prompt
+	merging        bool
+	pendingPrompts []models.Prompt
+	query          matcher.Query
+	queryErr       error
+	notice         string
+	sortMode       matcher.SortMode
+	strategy       matcher.Strategy
+	matcher        matcher.Matcher
+	weights        matcher.Weights
+	boosts         func(query string) matcher.Boosts
+	similar        *similar.Engine
+	similarTo      *models.Prompt
+	searchSeq      int
+	cancelSearch   context.CancelFunc
+	lastQuery      matcher.Query
+	lastMatches    []matcher.Match
+	index          *index.Index
+	snapshot       *index.Snapshot
+	promptsVersion int
+}
+
+type Option func(*Model)
+
+func WithTranscriptLoader(load TranscriptLoader) Option {
+	return func(m *Model) {
+		m.loadTranscript = load
+	}
 }
 
-func promptsToItems(prompts []models.Prompt) []list.Item {
-	items := make([]list.Item, len(prompts))
-	for i, p := range prompts {
-		items[i] = item{prompt: p}
+// WithRanking sets the initial sort mode and the weights used to blend
+// relevance, recency and frequency.
+func WithRanking(mode matcher.SortMode, weights matcher.Weights) Option {
+	return func(m *Model) {
+		m.sortMode = mode
+		m.weights = weights
+	}
+}
+
+// WithBoosts favours prompts that were picked before for similar queries.
+func WithBoosts(boosts func(query string) matcher.Boosts) Option {
+	return func(m *Model) {
+		m.boosts = boosts
+	}
+}
+
+// WithStrategy sets how the query's plain terms are matched at first.
+func WithStrategy(strategy matcher.Strategy) Option {
+	return func(m *Model) {
+		m.strategy = strategy
+	}
+}
+
+// WithIndex prunes searches with ix once it has caught up with the prompts.
+func WithIndex(ix *index.Index) Option {
+	return func(m *Model) {
+		m.index = ix
+	}
+}
+
+// WithGrouping groups the prompts in the background once the program starts,
+// showing them as they are until it is done. Prompts arriving meanwhile are
+// merged into what it returns.
+func WithGrouping(group func(prompts []models.Prompt) []models.Prompt) Option {
+	return func(m *Model) {
+		m.group = group
+	}
+}
+
+// WithMerge sets how prompts arriving while running are folded into the ones
+// shown. It runs off the UI goroutine, one call at a time, with existing
+// being what the previous call returned. By default only prompts with the
+// same text are merged.
+func WithMerge(merge func(existing, incoming []models.Prompt) []models.Prompt) Option {
+	return func(m *Model) {
+		m.merge = merge
 	}
-	return items
 }
 
 func configureListKeyMap(l *list.Model) {
@@ -168,9 +292,8 @@ func configureListKeyMap(l *list.Model) {
 	l.KeyMap.ForceQuit.SetEnabled(false)
 }
 
-func NewModel(prompts []models.Prompt) Model {
-	items := promptsToItems(prompts)
-	l := list.New(items, itemDelegate{}, defaultWidth, defaultHeight)
+func NewModel(prompts []models.Prompt, opts ...Option) Model {
+	l := list.New(nil, itemDelegate{}, defaultWidth, defaultHeight)
 	l.SetShowStatusBar(false)
 	l.SetFilteringEnabled(false)
 	l.SetShowHelp(false)
@@ -188,23 +311,64 @@ func NewModel(prompts []models.Prompt) Model {
 
 	vp := viewport.New(defaultWidth-2, defaultHeight)
 
-	return Model{
+	m := Model{
 		list:        l,
 		filterInput: ti,
 		viewport:    vp,
 		allPrompts:  prompts,
 		previewing:  false,
+		merge:       mergePrompts,
+		weights:     matcher.DefaultWeights,
+	}
+	for _, opt := range opts {
+		opt(&m)
 	}
+	// Merges wait for the grouping, as they build on what it returns.
+	m.merging = m.group != nil
+
+	// The first search runs before the program starts, so there is a list
+	// to show straight away.
+	if msg, err := m.newSearch(false).run(context.Background()); err == nil {
+		m.applySearch(msg)
+	}
+	return m
 }
 
 func (m Model) Init() tea.Cmd {
-	return nil
+	return tea.Batch(m.bindIndex(), m.groupPrompts())
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 
 	switch msg := msg.(type) {
+	case NewPromptsMsg:
+		m.pendingPrompts = append(m.pendingPrompts, msg.Prompts...)
+		return m, m.startMerge()
+
+	case promptsMergedMsg:
+		return m, m.applyMerge(msg)
+
+	case indexBoundMsg:
+		if msg.version == m.promptsVersion {
+			m.snapshot = msg.snapshot
+		}
+		return m, nil
+
+	case searchDebounceMsg:
+		if msg.seq != m.searchSeq {
+			return m, nil
+		}
+		return m, m.startSearch(false)
+
+	case searchResultMsg:
+		m.applySearch(msg)
+		return m, nil
+
+	case similarResultMsg:
+		m.applySimilar(msg)
+		return m, nil
+
 	case tea.WindowSizeMsg:
 		listHeight := msg.Height
 		if listHeight%2 != 0 {
@@ -226,11 +390,53 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case tea.KeyMsg:
+		if m.session != nil {
+			switch msg.String() {
+			case "esc":
+				m.session = nil
+				return m, nil
+			case "ctrl+c":
+				m.quitting = true
+				return m, tea.Quit
+			case "n":
+				if offset, ok := m.session.next(); ok {
+					m.viewport.SetYOffset(offset)
+				}
+				return m, nil
+			case "p":
+				if offset, ok := m.session.prev(); ok {
+					m.viewport.SetYOffset(offset)
+				}
+				return m, nil
+			default:
+				var cmd tea.Cmd
+				m.viewport, cmd = m.viewport.Update(msg)
+				return m, cmd
+			}
+		}
+
 		if m.previewing {
 			switch msg.String() {
 			case "esc":
 				m.previewing = false
 				return m, nil
+			case "ctrl+t":
+				if i, ok := m.list.SelectedItem().(item); ok && m.loadTranscript != nil {
+					m.previewing = false
+					m.openSession(i.prompt.Versions()[m.variant(i)])
+				}
+				return m, nil
+			case "n", "p":
+				if i, ok := m.list.SelectedItem().(item); ok {
+					n := len(i.prompt.Variants) + 1
+					if msg.String() == "n" {
+						m.previewVariant = (m.previewVariant + 1) % n
+					} else {
+						m.previewVariant = (m.previewVariant + n - 1) % n
+					}
+					m.showPreview(i)
+				}
+				return m, nil
 			case "ctrl+c":
 				m.quitting = true
 				return m, tea.Quit
@@ -241,6 +447,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+		m.notice = ""
 		switch msg.String() {
 		case "ctrl+c":
 			m.quitting = true
@@ -250,22 +457,21 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			i, ok := m.list.SelectedItem().(item)
 			if ok {
 				m.previewing = true
-				title := previewTitleStyle.Render("Preview - Press 'esc' to exit")
-				wrappedContent := lipgloss.NewStyle().Width(m.viewport.Width).Render(i.prompt.Display)
-				content := title + "\n\n" + wrappedContent
-				m.viewport.SetContent(content)
+				m.previewVariant = 0
+				m.showPreview(i)
 			}
 			return m, nil
 
 		case "esc":
+			if m.similarTo != nil {
+				return m, m.hideSimilar()
+			}
 			if m.filterInput.Value() != "" {
 				m.filterInput.SetValue("")
-				m.updateFilteredList()
-			} else {
-				m.quitting = true
-				return m, tea.Quit
+				return m, m.queryChanged()
 			}
-			return m, nil
+			m.quitting = true
+			return m, tea.Quit
 
 		case "enter":
 			i, ok := m.list.SelectedItem().(item)
@@ -274,27 +480,89 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			return m, tea.Quit
 
+		case "ctrl+t":
+			if i, ok := m.list.SelectedItem().(item); ok && m.loadTranscript != nil {
+				m.openSession(*i.prompt)
+			}
+			return m, nil
+
+		case "ctrl+r":
+			i, ok := m.list.SelectedItem().(item)
+			if !ok {
+				return m, nil
+			}
+			if i.prompt.Source != history.ClaudeSourceName || i.prompt.SessionID == "" {
+				m.notice = "Only " + history.ClaudeSourceName + " prompts with a session can be resumed"
+				return m, nil
+			}
+			m.resume = i.prompt
+			return m, tea.Quit
+
+		case "ctrl+l":
+			if i, ok := m.list.SelectedItem().(item); ok {
+				return m, m.showSimilar(i.prompt)
+			}
+			return m, nil
+
+		case "ctrl+s":
+			m.sortMode = m.sortMode.Next()
+			return m, m.restartSearch(true)
+
+		case "ctrl+g":
+			m.strategy = m.strategy.Next()
+			m.matcher = nil
+			return m, m.restartSearch(true)
+
 		case "up", "down", "pgup", "pgdown":
 			var cmd tea.Cmd
 			m.list, cmd = m.list.Update(msg)
 			return m, cmd
 
 		default:
+			previous := m.filterInput.Value()
 			var cmd tea.Cmd
 			m.filterInput, cmd = m.filterInput.Update(msg)
 			cmds = append(cmds, cmd)
-			m.updateFilteredList()
+			if m.filterInput.Value() != previous {
+				cmds = append(cmds, m.queryChanged())
+			}
 		}
 	}
 
 	return m, tea.Batch(cmds...)
 }
 
-func (m *Model) updateFilteredList() {
-	query := m.filterInput.Value()
-	filtered := matcher.MatchPrompts(m.allPrompts, query)
-	m.list.SetItems(promptsToItems(filtered))
-	m.list.Select(0)
+// mergePrompts folds incoming prompts into existing, keeping the newest copy
+// of each prompt along with every copy's occurrences.
+func mergePrompts(existing, incoming []models.Prompt) []models.Prompt {
+	newest := make(map[string]models.Prompt, len(incoming))
+	for _, p := range incoming {
+		if current, ok := newest[p.Display]; ok {
+			p = current.Merge(p)
+		}
+		newest[p.Display] = p
+	}
+
+	merged := make([]models.Prompt, 0, len(existing)+len(newest))
+	for _, p := range existing {
+		if n, ok := newest[p.Display]; ok {
+			newest[p.Display] = n.Merge(p)
+			continue
+		}
+		merged = append(merged, p)
+	}
+	for _, p := range incoming {
+		if n, ok := newest[p.Display]; ok {
+			merged = append(merged, n)
+			delete(newest, p.Display)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Timestamp > merged[j].Timestamp
+	})
+
+	return merged
 }
 
 func (m Model) View() string {
@@ -302,7 +570,7 @@ func (m Model) View() string {
 		return ""
 	}
 
-	if m.previewing {
+	if m.previewing || m.session != nil {
 		return "\n" + previewStyle.Render(m.viewport.View())
 	}
 
@@ -325,16 +593,66 @@ func (m Model) View() string {
 	s.WriteString(filterInputStyle.Render(m.filterInput.View()))
 	s.WriteString("\n")
 
+	if m.queryErr != nil {
+		s.WriteString(queryErrorStyle.Render(m.queryErr.Error()))
+		s.WriteString("\n")
+	}
+
+	if m.notice != "" {
+		s.WriteString(noticeStyle.Render(m.notice))
+		s.WriteString("\n")
+	}
+
+	if m.similarTo != nil {
+		s.WriteString(noticeStyle.Render("Similar to “" + firstLine(m.similarTo.Display) + "” • esc to go back"))
+		s.WriteString("\n")
+	}
+
 	if len(m.list.Items()) > 0 {
 		s.WriteString(paginationStyle.Render(m.list.Paginator.View()))
 		s.WriteString("\n")
 	}
 
-	s.WriteString(helpText)
+	s.WriteString(buildHelpText(m.sortMode, m.strategy))
 
 	return s.String()
 }
 
+// showPreview renders the variant of i being previewed.
+func (m *Model) showPreview(i item) {
+	variant := m.variant(i)
+	version := i.prompt.Versions()[variant]
+	m.viewport.SetContent(renderPreview(*i.prompt, variant, m.highlights(version.Display), m.viewport.Width))
+	m.viewport.GotoTop()
+}
+
+// variant returns which of i's versions is previewed, in case the list
+// changed underneath the preview.
+func (m Model) variant(i item) int {
+	return min(m.previewVariant, len(i.prompt.Variants))
+}
+
+// highlights returns the positions in text matched by the search shown, if
+// any.
+func (m Model) highlights(text string) []int {
+	if m.similarTo != nil {
+		return nil
+	}
+	return m.lastQuery.Highlights(text)
+}
+
 func (m Model) Choice() string {
 	return m.choice
 }
+
+// Query returns the search text as it was when the program exited.
+func (m Model) Query() string {
+	return m.filterInput.Value()
+}
+
+func (m Model) Resume() (models.Prompt, bool) {
+	if m.resume == nil {
+		return models.Prompt{}, false
+	}
+	return *m.resume, true
+}
@@ -0,0 +1,133 @@
+package ui
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"fpf/pkg/models"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestResumeOnlyClaudePrompts(t *testing.T) {
+	tests := []struct {
+		name   string
+		prompt models.Prompt
+		resume bool
+	}{
+		{"claude", models.Prompt{Display: "a", Source: "claude", SessionID: "s1"}, true},
+		{"codex", models.Prompt{Display: "a", Source: "codex", SessionID: "s1"}, false},
+		{"no session", models.Prompt{Display: "a", Source: "claude"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated, cmd := NewModel([]models.Prompt{tt.prompt}).Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+			m := updated.(Model)
+
+			_, resumed := m.Resume()
+			if resumed != tt.resume || (cmd != nil) != tt.resume {
+				t.Fatalf("ctrl+r resumed = %v with command %v, want %v", resumed, cmd != nil, tt.resume)
+			}
+			if hasNotice := strings.Contains(m.View(), "can be resumed"); hasNotice == tt.resume {
+				t.Errorf("View() shows the resume notice = %v, want %v", hasNotice, !tt.resume)
+			}
+		})
+	}
+}
+
+func TestMergePromptsReread(t *testing.T) {
+	streaming := models.Prompt{Display: "deploy", Timestamp: 5, File: "s.jsonl", Line: 2}
+	answered := streaming
+	answered.Replies = []string{"Deployed."}
+
+	merged := mergePrompts([]models.Prompt{streaming}, []models.Prompt{answered})
+	merged = mergePrompts(merged, []models.Prompt{answered, {Display: "deploy", Timestamp: 9, File: "s.jsonl", Line: 7}})
+
+	if len(merged) != 1 {
+		t.Fatalf("mergePrompts() = %v, want one prompt", merged)
+	}
+	if got := merged[0].UseCount(); got != 2 {
+		t.Errorf("UseCount() = %d, want 2", got)
+	}
+	if merged[0].Timestamp != 9 || merged[0].Timeline()[0].Line != 2 {
+		t.Errorf("mergePrompts() = %+v, want the newest copy with both occurrences", merged[0])
+	}
+}
+
+func TestMergeInBackground(t *testing.T) {
+	var calls [][]models.Prompt
+	merge := func(existing, incoming []models.Prompt) []models.Prompt {
+		calls = append(calls, incoming)
+		return append(slices.Clone(existing), incoming...)
+	}
+
+	var m tea.Model = NewModel([]models.Prompt{{Display: "first"}}, WithMerge(merge))
+	m, cmd := m.Update(NewPromptsMsg{Prompts: []models.Prompt{{Display: "second"}}})
+	if cmd == nil || len(calls) != 0 || len(m.(Model).allPrompts) != 1 {
+		t.Fatalf("NewPromptsMsg merged on the UI goroutine")
+	}
+
+	m, queued := m.Update(NewPromptsMsg{Prompts: []models.Prompt{{Display: "third"}}})
+	if queued != nil {
+		t.Fatalf("NewPromptsMsg started a merge while another was running")
+	}
+
+	m, cmd = m.Update(cmd())
+	if got := len(m.(Model).allPrompts); got != 2 {
+		t.Fatalf("allPrompts after the first merge = %d, want 2", got)
+	}
+
+	for _, c := range cmd().(tea.BatchMsg) {
+		if c == nil {
+			continue
+		}
+		if msg, ok := c().(promptsMergedMsg); ok {
+			m, _ = m.Update(msg)
+		}
+	}
+	if got := len(m.(Model).allPrompts); got != 3 || len(calls) != 2 {
+		t.Fatalf("allPrompts after the queued merge = %d in %d merges, want 3 in 2", got, len(calls))
+	}
+}
+
+func TestGroupInBackground(t *testing.T) {
+	var grouped, merged int
+	group := func(prompts []models.Prompt) []models.Prompt {
+		grouped++
+		return prompts[:1]
+	}
+	merge := func(existing, incoming []models.Prompt) []models.Prompt {
+		merged++
+		return append(slices.Clone(existing), incoming...)
+	}
+
+	var m tea.Model = NewModel([]models.Prompt{{Display: "run the tests"}, {Display: "Run the tests"}}, WithGrouping(group), WithMerge(merge))
+	cmd := m.Init()
+	if cmd == nil || grouped != 0 || len(m.(Model).allPrompts) != 2 {
+		t.Fatalf("NewModel grouped the prompts on the UI goroutine")
+	}
+
+	m, queued := m.Update(NewPromptsMsg{Prompts: []models.Prompt{{Display: "deploy"}}})
+	if queued != nil {
+		t.Fatalf("NewPromptsMsg started a merge before the prompts were grouped")
+	}
+
+	m, cmd = m.Update(cmd())
+	if got := len(m.(Model).allPrompts); grouped != 1 || got != 1 {
+		t.Fatalf("allPrompts after grouping = %d, want 1", got)
+	}
+
+	for _, c := range cmd().(tea.BatchMsg) {
+		if c == nil {
+			continue
+		}
+		if msg, ok := c().(promptsMergedMsg); ok {
+			m, _ = m.Update(msg)
+		}
+	}
+	if got := len(m.(Model).allPrompts); got != 2 || merged != 1 {
+		t.Fatalf("allPrompts after the queued merge = %d in %d merges, want 2 in 1", got, merged)
+	}
+}
@@ -0,0 +1,148 @@
+package ui
+
+import (
+	"strings"
+	"time"
+
+	"fpf/pkg/models"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+type TranscriptLoader func(models.Prompt) ([]models.Turn, error)
+
+var (
+	userLabelStyle        = lipgloss.NewStyle().Bold(true).Foreground(borderColor)
+	assistantLabelStyle   = lipgloss.NewStyle().Bold(true).Foreground(mutedColor)
+	highlightedLabelStyle = lipgloss.NewStyle().Bold(true).Foreground(accentColor)
+	turnTimeStyle         = lipgloss.NewStyle().Foreground(lightMutedColor)
+	toolCallStyle         = lipgloss.NewStyle().Foreground(mutedColor)
+	highlightedTurnStyle  = lipgloss.NewStyle().
+				Border(lipgloss.ThickBorder(), false, false, false, true).
+				BorderForeground(accentColor).
+				PaddingLeft(1)
+	sessionErrorStyle = lipgloss.NewStyle().Foreground(accentColor)
+)
+
+type sessionView struct {
+	userOffsets []int
+	current     int
+}
+
+func (s *sessionView) next() (int, bool) {
+	if s.current+1 >= len(s.userOffsets) {
+		return 0, false
+	}
+	s.current++
+	return s.userOffsets[s.current], true
+}
+
+func (s *sessionView) prev() (int, bool) {
+	if s.current <= 0 || len(s.userOffsets) == 0 {
+		return 0, false
+	}
+	s.current--
+	return s.userOffsets[s.current], true
+}
+
+func (m *Model) openSession(p models.Prompt) {
+	if m.loadTranscript == nil {
+		return
+	}
+
+	title := previewTitleStyle.Render("Session - 'n'/'p' to jump between prompts, 'esc' to exit")
+
+	turns, err := m.loadTranscript(p)
+	if err != nil {
+		m.session = &sessionView{}
+		m.viewport.SetContent(title + "\n\n" + sessionErrorStyle.Render("Could not load session: "+err.Error()))
+		m.viewport.GotoTop()
+		return
+	}
+
+	content, offsets, highlighted := renderTranscript(turns, p, m.viewport.Width)
+	titleLines := strings.Count(title, "\n") + 2
+	for i := range offsets {
+		offsets[i] += titleLines
+	}
+
+	m.session = &sessionView{userOffsets: offsets, current: highlighted}
+	m.viewport.SetContent(title + "\n\n" + content)
+	m.viewport.GotoTop()
+	if highlighted >= 0 && highlighted < len(offsets) {
+		m.viewport.SetYOffset(offsets[highlighted])
+	}
+}
+
+// renderTranscript renders turns for the session viewport. It returns the
+// line offset of every user turn and the index of the originating prompt
+// among them.
+func renderTranscript(turns []models.Turn, origin models.Prompt, width int) (string, []int, int) {
+	var (
+		b           strings.Builder
+		offsets     []int
+		highlighted = -1
+		line        int
+	)
+
+	for i, turn := range turns {
+		if i > 0 {
+			b.WriteString("\n\n")
+			line += 2
+		}
+
+		isOrigin := turn.Role == models.RoleUser && turn.Line == origin.Line
+		if turn.Role == models.RoleUser {
+			if isOrigin {
+				highlighted = len(offsets)
+			}
+			offsets = append(offsets, line)
+		}
+
+		rendered := renderTurn(turn, isOrigin, width)
+		b.WriteString(rendered)
+		line += strings.Count(rendered, "\n")
+	}
+
+	if highlighted < 0 {
+		highlighted = 0
+	}
+	return b.String(), offsets, highlighted
+}
+
+func renderTurn(turn models.Turn, highlighted bool, width int) string {
+	label := assistantLabelStyle.Render("Claude")
+	if turn.Role == models.RoleUser {
+		label = userLabelStyle.Render("You")
+		if highlighted {
+			label = highlightedLabelStyle.Render("You ▸ selected prompt")
+		}
+	}
+	if turn.Timestamp > 0 {
+		label += "  " + turnTimeStyle.Render(time.UnixMilli(turn.Timestamp).Format("2006-01-02 15:04"))
+	}
+
+	bodyWidth := width
+	if highlighted {
+		bodyWidth -= highlightedTurnStyle.GetHorizontalFrameSize()
+	}
+	bodyWidth = max(bodyWidth, 1)
+
+	parts := []string{label}
+	if text := strings.TrimSpace(turn.Text); text != "" {
+		parts = append(parts, lipgloss.NewStyle().Width(bodyWidth).Render(text))
+	}
+	for _, tool := range turn.Tools {
+		call := "▸ " + tool.Name
+		if tool.Summary != "" {
+			call += " " + tool.Summary
+		}
+		parts = append(parts, toolCallStyle.Width(bodyWidth).Render(call))
+	}
+
+	rendered := strings.Join(parts, "\n")
+	if highlighted {
+		return highlightedTurnStyle.Render(rendered)
+	}
+	return rendered
+}
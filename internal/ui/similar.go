@@ -0,0 +1,60 @@
+package ui
+
+import (
+	"fpf/internal/similar"
+	"fpf/pkg/models"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// similarLimit is how many similar prompts replace the list.
+const similarLimit = 50
+
+type similarResultMsg struct {
+	seq     int
+	version int
+	engine  *similar.Engine
+	items   []list.Item
+}
+
+// showSimilar replaces the list with the prompts most similar to p, building
+// the similarity engine in the background the first time.
+func (m *Model) showSimilar(p *models.Prompt) tea.Cmd {
+	if m.cancelSearch != nil {
+		m.cancelSearch()
+	}
+	m.searchSeq++
+	m.similarTo = p
+
+	seq, version, engine, prompts, text := m.searchSeq, m.promptsVersion, m.similar, m.allPrompts, p.Display
+	return func() tea.Msg {
+		if engine == nil {
+			engine = similar.NewEngine(prompts)
+		}
+
+		results := engine.Similar(text, similarLimit)
+		items := make([]list.Item, len(results))
+		for i, result := range results {
+			items[i] = item{prompt: result.Prompt, similarity: result.Score}
+		}
+		return similarResultMsg{seq: seq, version: version, engine: engine, items: items}
+	}
+}
+
+func (m *Model) applySimilar(msg similarResultMsg) {
+	if msg.version == m.promptsVersion {
+		m.similar = msg.engine
+	}
+	if msg.seq != m.searchSeq {
+		return
+	}
+	m.list.SetItems(msg.items)
+	m.list.Select(0)
+}
+
+// hideSimilar goes back to the search results.
+func (m *Model) hideSimilar() tea.Cmd {
+	m.similarTo = nil
+	return m.restartSearch(false)
+}
@@ -1,47 +1,120 @@
 package models
 
 import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 )
 
 type Prompt struct {
-	Display   string `json:"display"`
+	Display     string     `json:"display"`
+	Timestamp   int64      `json:"timestamp"`
+	Project     string     `json:"project"`
+	Source      string     `json:"source"`
+	SessionID   string     `json:"sessionId,omitempty"`
+	UUID        string     `json:"uuid,omitempty"`
+	ParentUUID  string     `json:"parentUuid,omitempty"`
+	GitBranch   string     `json:"gitBranch,omitempty"`
+	Model       string     `json:"model,omitempty"`
+	Version     string     `json:"version,omitempty"`
+	IsSidechain bool       `json:"isSidechain,omitempty"`
+	File        string     `json:"file,omitempty"`
+	Line        int        `json:"line,omitempty"`
+	Replies     []string   `json:"replies,omitempty"`
+	Tools       []ToolCall `json:"tools,omitempty"`
+	Uses        int        `json:"uses,omitempty"`
+	Variants    []Prompt   `json:"variants,omitempty"`
+	// Occurrences records every time the prompt was sent, once deduplication
+	// has merged more than one. A prompt without any occurred once, as itself.
+	Occurrences []Occurrence `json:"occurrences,omitempty"`
+
+	// hash is the Hash worked out by Hashed.
+	hash string
+}
+
+// Occurrence is one time a prompt was sent. UUID, File and Line tell it apart
+// from another copy of the prompt sent at the same time.
+type Occurrence struct {
 	Timestamp int64  `json:"timestamp"`
 	Project   string `json:"project"`
+	SessionID string `json:"sessionId,omitempty"`
+	UUID      string `json:"uuid,omitempty"`
+	File      string `json:"file,omitempty"`
+	Line      int    `json:"line,omitempty"`
+}
+
+// Same reports whether o and other are the same time a prompt was sent, read
+// twice, such as a prompt re-read while its reply is being written.
+func (o Occurrence) Same(other Occurrence) bool {
+	if o.UUID != "" || other.UUID != "" {
+		return o.UUID == other.UUID
+	}
+	return o.File != "" && o.Line == other.Line && o.File == other.File
+}
+
+func (o Occurrence) ProjectPath() string {
+	return shortenPath(o.Project)
 }
 
 func (p Prompt) Description() string {
-	projectPath := p.ProjectPath()
+	description := p.ProjectPath()
 	if timeAgo := p.TimeAgo(); timeAgo != "" {
-		return projectPath + " • " + timeAgo
+		description += " • " + timeAgo
+	}
+	if usage := p.Usage(); usage != "" {
+		description += " • " + usage
 	}
-	return projectPath
+	return description
+}
+
+// Usage describes how often and where a reused prompt was sent, e.g. "used
+// 14× across 5 projects". It is empty for prompts sent once.
+func (p Prompt) Usage() string {
+	uses := p.UseCount()
+	if uses < 2 {
+		return ""
+	}
+
+	projects := make(map[string]bool)
+	for _, o := range p.Timeline() {
+		projects[o.Project] = true
+	}
+	if len(projects) < 2 {
+		return "used " + strconv.Itoa(uses) + "×"
+	}
+	return "used " + strconv.Itoa(uses) + "× across " + strconv.Itoa(len(projects)) + " projects"
 }
 
 func (p Prompt) ProjectPath() string {
-	if p.Project == "" {
+	return shortenPath(p.Project)
+}
+
+func shortenPath(project string) string {
+	if project == "" {
 		return "no project"
 	}
 
 	home, err := os.UserHomeDir()
 	if err != nil {
-		return p.Project
+		return project
 	}
 
 	home = strings.TrimSuffix(home, string(filepath.Separator))
 
-	if p.Project == home {
+	if project == home {
 		return "~"
 	}
-	if strings.HasPrefix(p.Project, home+string(filepath.Separator)) {
-		return "~" + p.Project[len(home):]
+	if strings.HasPrefix(project, home+string(filepath.Separator)) {
+		return "~" + project[len(home):]
 	}
 
-	return p.Project
+	return project
 }
 
 type timeUnit struct {
@@ -80,3 +153,207 @@ func (p Prompt) TimeAgo() string {
 
 	return "just now"
 }
+
+// Hash identifies the prompt's text without storing it, for recording which
+// prompts were picked.
+func (p Prompt) Hash() string {
+	if p.hash != "" {
+		return p.hash
+	}
+	sum := sha256.Sum256([]byte(p.Display))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Hashed returns p with its Hash worked out, so it isn't worked out again each
+// time p is ranked.
+func (p Prompt) Hashed() Prompt {
+	p.hash = p.Hash()
+	return p
+}
+
+// UseCount returns how many times the prompt or any of its variants was sent.
+// Prompts that haven't been through deduplication count once.
+func (p Prompt) UseCount() int {
+	uses := max(p.Uses, 1)
+	for _, variant := range p.Variants {
+		uses += variant.UseCount()
+	}
+	return uses
+}
+
+// Versions returns the prompt followed by its variants, each without
+// variants of its own.
+func (p Prompt) Versions() []Prompt {
+	versions := make([]Prompt, 0, len(p.Variants)+1)
+	versions = append(versions, p)
+	versions[0].Variants = nil
+	return append(versions, p.Variants...)
+}
+
+// Merge combines two copies of the same prompt, keeping the details of the
+// newer one, or p's if neither is newer, and adding up their uses and
+// occurrences. Occurrences of other that p already has are re-reads rather
+// than uses, and replace p's copies, so a prompt re-read as its reply is
+// written keeps the latest reply.
+func (p Prompt) Merge(other Prompt) Prompt {
+	p.Occurrences = slices.Clip(p.Occurrences)
+	p.Absorb(other)
+	return p
+}
+
+// Absorb merges other into p as Merge does, but appends to p's Occurrences in
+// place, so absorbing many copies in turn takes linear time. p's Occurrences
+// must not have spare capacity that other slices append to.
+func (p *Prompt) Absorb(other Prompt) {
+	occurrences := p.occurrences()
+	uses := max(p.Uses, 1) + max(other.Uses, 1)
+	if other.Timestamp > p.Timestamp || other.occurrence().Same(p.occurrence()) {
+		if other.hash == "" && other.Display == p.Display {
+			other.hash = p.hash
+		}
+		*p = other
+	}
+
+	for _, o := range other.occurrences() {
+		if i := slices.IndexFunc(occurrences, o.Same); i >= 0 {
+			// Earlier copies of p may share the occurrences, so they are
+			// copied before being changed.
+			occurrences = slices.Clone(occurrences)
+			occurrences[i] = o
+			uses--
+			continue
+		}
+		occurrences = append(occurrences, o)
+	}
+
+	p.Uses = uses
+	p.Occurrences = occurrences
+}
+
+func (p Prompt) occurrences() []Occurrence {
+	if len(p.Occurrences) > 0 {
+		return p.Occurrences
+	}
+	return []Occurrence{p.occurrence()}
+}
+
+func (p Prompt) occurrence() Occurrence {
+	return Occurrence{
+		Timestamp: p.Timestamp,
+		Project:   p.Project,
+		SessionID: p.SessionID,
+		UUID:      p.UUID,
+		File:      p.File,
+		Line:      p.Line,
+	}
+}
+
+// Timeline returns every occurrence of the prompt and its variants, oldest
+// first.
+func (p Prompt) Timeline() []Occurrence {
+	var timeline []Occurrence
+	for _, version := range p.Versions() {
+		timeline = append(timeline, version.occurrences()...)
+	}
+	slices.SortStableFunc(timeline, func(a, b Occurrence) int {
+		return cmp.Compare(a.Timestamp, b.Timestamp)
+	})
+	return timeline
+}
+
+func (p Prompt) FirstReply() string {
+	if len(p.Replies) == 0 {
+		return ""
+	}
+	return p.Replies[0]
+}
+
+func (p Prompt) ReplyText() string {
+	return strings.Join(p.Replies, "\n\n")
+}
+
+// TouchedFiles returns the distinct files the reply read or changed, in the
+// order they were first touched.
+func (p Prompt) TouchedFiles() []string {
+	var files []string
+	seen := make(map[string]bool)
+	for _, tool := range p.Tools {
+		if tool.FilePath == "" || seen[tool.FilePath] {
+			continue
+		}
+		seen[tool.FilePath] = true
+		files = append(files, tool.FilePath)
+	}
+	return files
+}
+
+type toolCategory struct {
+	tools    []string
+	verb     string
+	singular string
+	plural   string
+	perFile  bool
+}
+
+var toolCategories = []toolCategory{
+	{[]string{"Edit", "MultiEdit", "NotebookEdit"}, "edited", "file", "files", true},
+	{[]string{"Write"}, "wrote", "file", "files", true},
+	{[]string{"Read"}, "read", "file", "files", true},
+	{[]string{"Bash"}, "ran", "Bash command", "Bash commands", false},
+	{[]string{"Grep", "Glob"}, "ran", "search", "searches", false},
+	{[]string{"WebFetch", "WebSearch"}, "made", "web request", "web requests", false},
+	{[]string{"Task"}, "started", "subagent", "subagents", false},
+}
+
+// ToolSummary describes the tool calls made in reply to the prompt, e.g.
+// "Edited 3 files, ran 2 Bash commands".
+func (p Prompt) ToolSummary() string {
+	if len(p.Tools) == 0 {
+		return ""
+	}
+
+	var phrases []string
+	counted := make(map[string]bool)
+
+	for _, category := range toolCategories {
+		count := 0
+		files := make(map[string]bool)
+		for _, tool := range p.Tools {
+			if !slices.Contains(category.tools, tool.Name) {
+				continue
+			}
+			counted[tool.Name] = true
+			if category.perFile && tool.FilePath != "" {
+				files[tool.FilePath] = true
+			} else {
+				count++
+			}
+		}
+		count += len(files)
+
+		if count > 0 {
+			phrases = append(phrases, pluralize(category.verb, count, category.singular, category.plural))
+		}
+	}
+
+	others := 0
+	for _, tool := range p.Tools {
+		if !counted[tool.Name] {
+			others++
+		}
+	}
+	if others > 0 {
+		phrases = append(phrases, pluralize("used", others, "other tool", "other tools"))
+	}
+
+	summary := strings.Join(phrases, ", ")
+	return strings.ToUpper(summary[:1]) + summary[1:]
+}
+
+func pluralize(verb string, count int, singular, plural string) string {
+	noun := plural
+	if count == 1 {
+		noun = singular
+	}
+	return verb + " " + strconv.Itoa(count) + " " + noun
+}
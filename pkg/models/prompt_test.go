@@ -3,6 +3,8 @@ package models
 import (
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -69,10 +71,10 @@ func TestDescription(t *testing.T) {
 	}
 
 	tests := []struct {
-		name      string
-		prompt    Prompt
-		wantPath  string
-		wantTime  bool
+		name     string
+		prompt   Prompt
+		wantPath string
+		wantTime bool
 	}{
 		{
 			name: "with timestamp",
@@ -112,6 +114,65 @@ func TestDescription(t *testing.T) {
 	}
 }
 
+func TestToolSummary(t *testing.T) {
+	tests := []struct {
+		name     string
+		tools    []ToolCall
+		expected string
+	}{
+		{
+			name:     "no tools",
+			expected: "",
+		},
+		{
+			name: "edits counted per file",
+			tools: []ToolCall{
+				{Name: "Edit", FilePath: "/app/a.go"},
+				{Name: "Edit", FilePath: "/app/a.go"},
+				{Name: "MultiEdit", FilePath: "/app/b.go"},
+				{Name: "Edit", FilePath: "/app/c.go"},
+				{Name: "Bash"},
+				{Name: "Bash"},
+			},
+			expected: "Edited 3 files, ran 2 Bash commands",
+		},
+		{
+			name: "singular and other tools",
+			tools: []ToolCall{
+				{Name: "Read", FilePath: "/app/a.go"},
+				{Name: "Grep"},
+				{Name: "TodoWrite"},
+				{Name: "mcp__github__create_issue"},
+			},
+			expected: "Read 1 file, ran 1 search, used 2 other tools",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Prompt{Tools: tt.tools}
+			if got := p.ToolSummary(); got != tt.expected {
+				t.Errorf("ToolSummary() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTouchedFiles(t *testing.T) {
+	p := Prompt{Tools: []ToolCall{
+		{Name: "Read", FilePath: "/app/b.go"},
+		{Name: "Bash"},
+		{Name: "Edit", FilePath: "/app/a.go"},
+		{Name: "Edit", FilePath: "/app/b.go"},
+	}}
+
+	got := p.TouchedFiles()
+	want := []string{"/app/b.go", "/app/a.go"}
+	if !slices.Equal(got, want) {
+		t.Errorf("TouchedFiles() = %q, expected %q", got, want)
+	}
+}
+
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(s) > len(substr) && findSubstring(s, substr))
 }
@@ -124,3 +185,97 @@ func findSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+func TestUsage(t *testing.T) {
+	tests := []struct {
+		name   string
+		prompt Prompt
+		want   string
+	}{
+		{"sent once", Prompt{Project: "/a"}, ""},
+		{"one project", Prompt{Project: "/a", Uses: 2, Occurrences: []Occurrence{{Project: "/a"}, {Project: "/a"}}}, "used 2×"},
+		{
+			"several projects",
+			Prompt{Project: "/a", Uses: 3, Occurrences: []Occurrence{{Project: "/a"}, {Project: "/b"}, {Project: "/a"}}, Variants: []Prompt{{Project: "/c"}}},
+			"used 4× across 3 projects",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.prompt.Usage(); got != tt.want {
+				t.Errorf("Usage() = %q, want %q", got, tt.want)
+			}
+			if tt.want != "" && !strings.HasSuffix(tt.prompt.Description(), " • "+tt.want) {
+				t.Errorf("Description() = %q, want it to end with the usage", tt.prompt.Description())
+			}
+		})
+	}
+}
+
+func TestMergeTimeline(t *testing.T) {
+	a := Prompt{Display: "deploy", Timestamp: 5, Project: "/a", SessionID: "s5"}
+	b := Prompt{Display: "deploy", Timestamp: 9, Project: "/b", SessionID: "s9"}
+	c := Prompt{Display: "deploy", Timestamp: 1, Project: "/a", SessionID: "s1"}
+
+	merged := a.Merge(b).Merge(c)
+	if merged.Timestamp != 9 || merged.Project != "/b" || merged.UseCount() != 3 {
+		t.Errorf("Merge() = %+v, want the newest copy used 3 times", merged)
+	}
+
+	want := []Occurrence{
+		{Timestamp: 1, Project: "/a", SessionID: "s1"},
+		{Timestamp: 5, Project: "/a", SessionID: "s5"},
+		{Timestamp: 9, Project: "/b", SessionID: "s9"},
+	}
+	if got := merged.Timeline(); !slices.Equal(got, want) {
+		t.Errorf("Timeline() = %v, want %v", got, want)
+	}
+}
+
+func TestMergeReread(t *testing.T) {
+	older := Prompt{Display: "deploy", Timestamp: 1, File: "a.jsonl", Line: 3}
+	streaming := Prompt{Display: "deploy", Timestamp: 5, File: "b.jsonl", Line: 7}
+	answered := streaming
+	answered.Replies = []string{"Deployed."}
+
+	tests := []struct {
+		name   string
+		merged Prompt
+		uses   int
+	}{
+		{"re-read of the newest copy", older.Merge(streaming).Merge(answered), 2},
+		{"re-read alone", streaming.Merge(answered), 1},
+		{"re-read of an older copy", streaming.Merge(older).Merge(older), 2},
+		{"same line of another file", older.Merge(Prompt{Display: "deploy", Timestamp: 1, File: "c.jsonl", Line: 3}), 2},
+		{"re-read by UUID", Prompt{UUID: "u1", Timestamp: 1}.Merge(Prompt{UUID: "u1", Timestamp: 1, Replies: []string{"Deployed."}}), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.merged.UseCount(); got != tt.uses {
+				t.Errorf("UseCount() = %d, want %d", got, tt.uses)
+			}
+			if got := len(tt.merged.Timeline()); got != tt.uses {
+				t.Errorf("Timeline() has %d occurrences, want %d", got, tt.uses)
+			}
+		})
+	}
+
+	if merged := streaming.Merge(answered); merged.FirstReply() != "Deployed." {
+		t.Errorf("Merge() of a re-read copy kept replies %q, want the re-read's", merged.Replies)
+	}
+}
+
+func TestHashed(t *testing.T) {
+	p := Prompt{Display: "run the tests", Timestamp: 1}
+	hashed := p.Hashed()
+	if hashed.hash == "" || hashed.Hash() != p.Hash() {
+		t.Fatalf("Hashed().Hash() = %q, want %q", hashed.Hash(), p.Hash())
+	}
+
+	hashed.Absorb(Prompt{Display: "run the tests", Timestamp: 2})
+	if hashed.hash != p.Hash() {
+		t.Errorf("Absorb() of a newer copy left hash %q, want %q", hashed.hash, p.Hash())
+	}
+}
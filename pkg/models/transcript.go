@@ -0,0 +1,21 @@
+package models
+
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+type ToolCall struct {
+	Name     string `json:"name"`
+	Summary  string `json:"summary,omitempty"`
+	FilePath string `json:"filePath,omitempty"`
+}
+
+type Turn struct {
+	Role      string     `json:"role"`
+	Text      string     `json:"text"`
+	Tools     []ToolCall `json:"tools,omitempty"`
+	UUID      string     `json:"uuid,omitempty"`
+	Timestamp int64      `json:"timestamp"`
+	Line      int        `json:"line"`
+}